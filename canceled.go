@@ -0,0 +1,46 @@
+package sec
+
+import (
+	"fmt"
+)
+
+// CanceledError is returned by ParseContext() if context was done while
+// sources were loaded or field's value was resolved.
+type CanceledError struct {
+	// Path is a path to field, like "Database.Password". Empty if
+	// context was done while sources were loaded or while key which
+	// doesn't belong to field (like profile variable) was read.
+	Path string
+	// Key is a key (environment variable name) composed for field or
+	// key that was read.
+	Key string
+	// Err is a context's error.
+	Err error
+}
+
+// Error returns error's text with field that was resolved.
+func (e *CanceledError) Error() string {
+	switch {
+	case e.Path == "" && e.Key == "":
+		return fmt.Sprintf("parsing was canceled while loading sources: %s", e.Err.Error())
+	case e.Path == "":
+		return fmt.Sprintf("parsing was canceled while reading '%s': %s", e.Key, e.Err.Error())
+	}
+
+	return fmt.Sprintf("parsing was canceled while resolving field '%s' (%s): %s", e.Path, e.Key, e.Err.Error())
+}
+
+// Unwrap returns context's error, so errors.Is(err,
+// context.DeadlineExceeded) works.
+func (e *CanceledError) Unwrap() error {
+	return e.Err
+}
+
+// Returns CanceledError for field if parser's context is done.
+func (p *parser) canceled(element *field) error {
+	if err := p.ctx.Err(); err != nil {
+		return &CanceledError{Path: element.Path, Key: element.EnvVar, Err: err}
+	}
+
+	return nil
+}
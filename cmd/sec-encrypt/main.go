@@ -0,0 +1,81 @@
+// sec-encrypt encrypts values for using them with sec's
+// DecryptionKeyVariable option.
+//
+// Generate key:
+//
+//	sec-encrypt -generate > key
+//
+// Encrypt value read from standard input (so it won't get into shell
+// history) with key from file or environment variable:
+//
+//	echo -n "password" | sec-encrypt -key-file key
+//	echo -n "password" | SEC_KEY="..." sec-encrypt
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"go.dev.pztrn.name/sec"
+)
+
+func main() {
+	generate := flag.Bool("generate", false, "Generate new key and print it")
+	keyFile := flag.String("key-file", "", "Path to file with base64-encoded key")
+	keyVariable := flag.String(
+		"key-variable", "SEC_KEY", "Name of environment variable with base64-encoded key, used if -key-file isn't set",
+	)
+
+	flag.Parse()
+
+	if *generate {
+		key, err := sec.GenerateKey()
+		if err != nil {
+			log.Fatalln("Failed to generate key:", err.Error())
+		}
+
+		fmt.Println(key)
+
+		return
+	}
+
+	key, err := readKey(*keyFile, *keyVariable)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	value, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatalln("Failed to read value from standard input:", err.Error())
+	}
+
+	encrypted, err := sec.Encrypt(key, strings.TrimRight(string(value), "\r\n"))
+	if err != nil {
+		log.Fatalln("Failed to encrypt value:", err.Error())
+	}
+
+	fmt.Println(encrypted)
+}
+
+// Reads key from file or environment variable.
+func readKey(keyFile, keyVariable string) (string, error) {
+	if keyFile != "" {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read key: %w", err)
+		}
+
+		return string(data), nil
+	}
+
+	key, found := os.LookupEnv(keyVariable)
+	if !found {
+		return "", fmt.Errorf("neither -key-file nor %s environment variable are set", keyVariable)
+	}
+
+	return key, nil
+}
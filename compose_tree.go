@@ -1,12 +1,23 @@
 package sec
 
 import (
+	"errors"
+	"fmt"
 	"reflect"
+	"sort"
 	"strings"
+	"unsafe"
 )
 
-// Composes full tree for every structure member.
-func composeTree(value reflect.Value, prefix string) {
+var (
+	errEnvNameCollision = errors.New("environment variable name collision")
+	errRecursiveType    = errors.New("recursive type found")
+)
+
+// Composes full tree for every structure member. Types is a list of
+// structure types that are on the path to passed value and used for
+// recursive types detection.
+func (p *parser) composeTree(value reflect.Value, prefix, path string, types []reflect.Type) error {
 	typeOf := value.Type()
 
 	// Compose prefix for everything below current field.
@@ -28,63 +39,130 @@ func composeTree(value reflect.Value, prefix string) {
 		}
 	}
 
-	if value.Kind() != reflect.Struct {
+	// Secrets are structures, but they are filled as strings.
+	isStruct := value.Kind() == reflect.Struct && typeOf != secretType
+
+	if isStruct {
+		skip, err := p.checkNesting(typeOf, path, types)
+		if err != nil || skip {
+			return err
+		}
+
+		types = append(types, typeOf)
+	}
+
+	if !isStruct {
 		if value.Kind() == reflect.Map {
 			newElementPrefix := curPrefix
 
 			mapIter := value.MapRange()
 			for mapIter.Next() {
-				composeTree(mapIter.Value().Elem(), newElementPrefix+"_"+strings.ToUpper(mapIter.Key().String()))
+				err := p.composeTree(
+					mapIter.Value().Elem(),
+					newElementPrefix+"_"+strings.ToUpper(mapIter.Key().String()),
+					mapPath(path, mapIter.Key().String()),
+					types,
+				)
+				if err != nil {
+					return err
+				}
 			}
 		} else {
-			f := &field{
+			p.addField(&field{
 				Name:    typeOf.Name(),
+				Path:    path,
 				EnvVar:  curPrefix + strings.ToUpper(typeOf.Name()),
 				Pointer: value,
 				Kind:    value.Kind(),
-			}
-
-			parsedTree = append(parsedTree, f)
-
-			printDebug("Field data constructed (start): %+v", f)
+				Tag:     tagOptions{},
+			}, "start")
 		}
 
-		return
+		return nil
 	}
 
 	for i := 0; i < value.NumField(); i++ {
 		fieldToProcess := value.Field(i)
 		fieldToProcessType := typeOf.Field(i)
+		fieldPath := joinPath(path, fieldToProcessType.Name)
+
+		// Unexported fields aren't settable using 'reflect' package,
+		// so if user asked for it we'll get them using unsafe pointers.
+		// This is possible only for addressable structures. Internals
+		// of types from other packages (like time.Time) are left alone.
+		if p.options.AllowUnexported && fieldToProcessType.PkgPath != "" &&
+			fieldToProcessType.PkgPath == p.packagePath && fieldToProcess.CanAddr() {
+			printDebug("Field '%s' is unexported, making it settable", fieldToProcessType.Name)
+
+			fieldToProcess = exposeField(fieldToProcess)
+		}
 
 		// If currently processed field - interface, then we should
 		// get underlying value.
 		if fieldToProcess.Kind() == reflect.Interface {
+			err := p.createImplementation(fieldToProcess, curPrefix+strings.ToUpper(fieldToProcessType.Name), fieldPath)
+			if err != nil {
+				return err
+			}
+
 			if fieldToProcess.Elem().Kind() == reflect.Ptr {
+				// Typed nil pointer can be replaced only if user asked
+				// for it.
+				if fieldToProcess.Elem().IsNil() {
+					if !p.options.ReplaceInterfaceValues || !fieldToProcess.CanSet() {
+						printDebug("Field '%s' holds nil pointer and will be ignored", fieldToProcessType.Name)
+
+						continue
+					}
+
+					printDebug("Field '%s' holds nil pointer, initializing new one", fieldToProcessType.Name)
+
+					fieldToProcess.Set(reflect.New(fieldToProcess.Elem().Type().Elem()))
+				}
+
 				fieldToProcess = fieldToProcess.Elem().Elem()
+			} else if p.options.ReplaceInterfaceValues && fieldToProcess.CanSet() && fieldToProcess.Elem().IsValid() {
+				fieldToProcess = p.copyInterfaceValue(fieldToProcess)
 			} else if fieldToProcess.Elem().Kind() == reflect.Struct {
 				fieldToProcess = fieldToProcess.Elem()
 			}
 		}
 
+		// Recursive types should be checked before initializing nil
+		// pointers, otherwise we will allocate them forever.
+		if structType, isStruct := underlyingStruct(fieldToProcess.Type()); isStruct && structType != secretType {
+			skip, err := p.checkNesting(structType, fieldPath, types)
+			if err != nil {
+				return err
+			}
+
+			if skip {
+				continue
+			}
+		}
+
 		// In 99% of cases we will get uninitialized things we should
-		// initialize.
+		// initialize. Slices will be created while filling values.
 		switch fieldToProcess.Kind() {
-		case reflect.Ptr, reflect.Map, reflect.Slice:
+		case reflect.Ptr, reflect.Map:
 			if fieldToProcess.IsNil() {
 				printDebug("Field '%s' is nil, initializing new one", fieldToProcessType.Name)
 
 				// We should use only exported fields as unexported aren't
-				// settable using 'reflect' package. Can be possibly solved
-				// using unsafe pointers?
-				if fieldToProcess.CanSet() {
-					fieldToProcess.Set(reflect.New(fieldToProcess.Type().Elem()))
-				} else {
+				// settable using 'reflect' package, unless they were
+				// exposed above.
+				if !fieldToProcess.CanSet() {
 					printDebug("Field '%s' is unexported and will be ignored", fieldToProcessType.Name)
 
 					continue
 				}
 
-				fieldToProcess = fieldToProcess.Elem()
+				if fieldToProcess.Kind() == reflect.Map {
+					fieldToProcess.Set(reflect.MakeMap(fieldToProcess.Type()))
+				} else {
+					fieldToProcess.Set(reflect.New(fieldToProcess.Type().Elem()))
+					fieldToProcess = fieldToProcess.Elem()
+				}
 			}
 		}
 
@@ -104,7 +182,7 @@ func composeTree(value reflect.Value, prefix string) {
 			}
 		}
 
-		if fieldToProcess.Kind() != reflect.Struct && !fieldToProcess.CanSet() {
+		if (fieldToProcess.Kind() != reflect.Struct || fieldToProcess.Type() == secretType) && !fieldToProcess.CanSet() {
 			printDebug("Field '%s' of type '%s' can't be set, skipping",
 				fieldToProcessType.Name,
 				fieldToProcess.Type().Kind().String())
@@ -116,15 +194,19 @@ func composeTree(value reflect.Value, prefix string) {
 
 		// Hello, I'm recursion and I'm here to make you happy.
 		// I'll be launched only for structures to get their fields.
-		switch fieldToProcess.Kind() {
-		case reflect.Struct:
+		// Secrets are structures too, but they are filled as strings.
+		switch {
+		case fieldToProcess.Kind() == reflect.Struct && fieldToProcess.Type() != secretType:
 			newElementPrefix := curPrefix
 			if !fieldToProcessType.Anonymous {
 				newElementPrefix = strings.ToUpper(newElementPrefix + typeOf.Field(i).Name)
 			}
 
-			composeTree(fieldToProcess, newElementPrefix)
-		case reflect.Map:
+			err := p.composeTree(fieldToProcess, newElementPrefix, fieldPath, types)
+			if err != nil {
+				return err
+			}
+		case fieldToProcess.Kind() == reflect.Map:
 			newElementPrefix := curPrefix
 			if !fieldToProcessType.Anonymous {
 				newElementPrefix = strings.ToUpper(newElementPrefix + typeOf.Field(i).Name)
@@ -132,19 +214,169 @@ func composeTree(value reflect.Value, prefix string) {
 
 			mapIter := fieldToProcess.MapRange()
 			for mapIter.Next() {
-				composeTree(mapIter.Value().Elem(), newElementPrefix+"_"+strings.ToUpper(mapIter.Key().String()))
+				err := p.composeTree(
+					mapIter.Value().Elem(),
+					newElementPrefix+"_"+strings.ToUpper(mapIter.Key().String()),
+					mapPath(fieldPath, mapIter.Key().String()),
+					types,
+				)
+				if err != nil {
+					return err
+				}
 			}
 		default:
-			f := &field{
-				Name:    typeOf.Field(i).Name,
-				EnvVar:  curPrefix + strings.ToUpper(typeOf.Field(i).Name),
-				Pointer: fieldToProcess,
-				Kind:    fieldToProcess.Kind(),
-			}
+			p.addField(&field{
+				Name:      typeOf.Field(i).Name,
+				Path:      fieldPath,
+				EnvVar:    curPrefix + strings.ToUpper(typeOf.Field(i).Name),
+				Pointer:   fieldToProcess,
+				Kind:      fieldToProcess.Kind(),
+				Tag:       parseTag(fieldToProcessType.Tag.Get(tagName)),
+				StructTag: fieldToProcessType.Tag,
+			}, "end")
+		}
+	}
+
+	return nil
+}
+
+// Copies value from interface into new addressable value which will
+// be stored back into interface after parsing.
+func (p *parser) copyInterfaceValue(iface reflect.Value) reflect.Value {
+	value := reflect.New(iface.Elem().Type()).Elem()
+	value.Set(iface.Elem())
 
-			parsedTree = append(parsedTree, f)
+	p.interfaceValues = append(p.interfaceValues, &interfaceValue{
+		Interface: iface,
+		Value:     value,
+	})
 
-			printDebug("Field data constructed (end): %+v", f)
+	printDebug("Value of type '%s' in interface was copied and will be replaced after parsing", value.Type().String())
+
+	return value
+}
+
+// Stores copied values back into interfaces.
+func (p *parser) storeInterfaceValues() {
+	for _, element := range p.interfaceValues {
+		element.Interface.Set(element.Value)
+	}
+}
+
+// Makes unexported field settable using unsafe pointer to it. Passed
+// value should be addressable.
+func exposeField(value reflect.Value) reflect.Value {
+	// nolint:gosec
+	return reflect.NewAt(value.Type(), unsafe.Pointer(value.UnsafeAddr())).Elem()
+}
+
+// Returns path of package structure type was declared in. Unnamed
+// structures don't have package, so it is taken from their unexported
+// fields.
+func structPackage(typeOf reflect.Type) string {
+	if typeOf.PkgPath() != "" {
+		return typeOf.PkgPath()
+	}
+
+	for i := 0; i < typeOf.NumField(); i++ {
+		if pkgPath := typeOf.Field(i).PkgPath; pkgPath != "" {
+			return pkgPath
+		}
+	}
+
+	return ""
+}
+
+// Adds field to parsed tree.
+func (p *parser) addField(f *field, stage string) {
+	f.Secret = p.isSecret(f)
+
+	p.tree = append(p.tree, f)
+
+	printDebug("Field data constructed (%s): name '%s', path '%s', variable '%s', kind %s, secret %t",
+		stage, f.Name, f.Path, f.EnvVar, f.Kind.String(), f.Secret)
+}
+
+// Checks parsed tree for fields that are mapped to same environment
+// variable. Every collision will be listed in returned error unless
+// sharing of environment variables was explicitly allowed. Fields mapped
+// to variables which choose interfaces implementations are always
+// reported.
+func (p *parser) checkCollisions() error {
+	paths := make(map[string][]string)
+
+	for _, element := range p.tree {
+		paths[element.EnvVar] = append(paths[element.EnvVar], element.Path)
+	}
+
+	collisions := make([]string, 0)
+
+	for envVar, fieldPaths := range paths {
+		// Discriminators are reserved even if sharing is allowed.
+		if interfacePath, isDiscriminator := p.discriminators[envVar]; isDiscriminator {
+			collisions = append(collisions, fmt.Sprintf("'%s' is used by %s and chooses implementation for %s",
+				envVar, strings.Join(fieldPaths, ", "), interfacePath))
+
+			continue
+		}
+
+		if len(fieldPaths) > 1 && !p.options.AllowSharedEnvNames {
+			collisions = append(collisions, fmt.Sprintf("'%s' is used by %s", envVar, strings.Join(fieldPaths, ", ")))
+		}
+	}
+
+	if len(collisions) == 0 {
+		return nil
+	}
+
+	sort.Strings(collisions)
+
+	return fmt.Errorf("%w: %s", errEnvNameCollision, strings.Join(collisions, "; "))
+}
+
+// Checks if structure of passed type can be processed at passed path.
+// If maximum depth was configured, then too deeply nested structures
+// (including recursive ones) should be skipped. Otherwise recursive
+// types will produce an error.
+func (p *parser) checkNesting(typeOf reflect.Type, path string, types []reflect.Type) (bool, error) {
+	if p.options.MaxDepth > 0 {
+		if len(types) >= p.options.MaxDepth {
+			printDebug("Field '%s' is nested deeper than %d levels and will be ignored", path, p.options.MaxDepth)
+
+			return true, nil
+		}
+
+		return false, nil
+	}
+
+	for _, t := range types {
+		if t == typeOf {
+			return false, fmt.Errorf("%w: field '%s' of type '%s' refers to itself", errRecursiveType, path, typeOf.String())
 		}
 	}
+
+	return false, nil
+}
+
+// Returns structure type that might be hidden behind pointers.
+func underlyingStruct(typeOf reflect.Type) (reflect.Type, bool) {
+	for typeOf.Kind() == reflect.Ptr {
+		typeOf = typeOf.Elem()
+	}
+
+	return typeOf, typeOf.Kind() == reflect.Struct
+}
+
+// Joins field path with field name.
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+
+	return path + "." + name
+}
+
+// Composes path for map element.
+func mapPath(path, key string) string {
+	return path + "[" + key + "]"
 }
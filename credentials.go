@@ -0,0 +1,155 @@
+package sec
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Name of environment variable systemd puts credentials directory path
+// into.
+const credentialsDirectoryEnvName = "CREDENTIALS_DIRECTORY"
+
+// CredentialsOptions represents configuration for CredentialsSource.
+type CredentialsOptions struct {
+	// Directory with credentials. Defaults to directory from
+	// $CREDENTIALS_DIRECTORY environment variable.
+	Directory string
+	// All indicates that every field should be looked up in credentials
+	// directory, not only ones with `sec:"credential"` tag.
+	All bool
+	// MaxFileSize is a maximum size of credential in bytes. Defaults to
+	// 1 MiB.
+	MaxFileSize int64
+}
+
+// CredentialsSource is a source which reads systemd credentials
+// (configured with LoadCredential= and similar directives) from
+// $CREDENTIALS_DIRECTORY. Only fields with `sec:"credential"` tag are
+// looked up there unless CredentialsOptions.All was set. Credential
+// name is a lowercased field's key (e.g. "database_password") or might
+// be defined in tag: `sec:"credential=db-password"`. Profile specific
+// credentials have profile suffix, like "db-password__production". If
+// credential wasn't found (or credentials directory isn't set, e.g.
+// when running in container) value will be taken from fallback source.
+type CredentialsSource struct {
+	fallback Source
+	options  CredentialsOptions
+}
+
+// NewCredentialsSource creates new systemd credentials source. If
+// fallback is nil - operating system's environment will be used. If
+// options are nil - default ones will be used.
+func NewCredentialsSource(fallback Source, config *CredentialsOptions) *CredentialsSource {
+	if fallback == nil {
+		fallback = EnvSource{}
+	}
+
+	options := CredentialsOptions{}
+	if config != nil {
+		options = *config
+	}
+
+	if options.Directory == "" {
+		options.Directory = os.Getenv(credentialsDirectoryEnvName)
+	}
+
+	if options.MaxFileSize <= 0 {
+		options.MaxFileSize = defaultMaxFileSize
+	}
+
+	return &CredentialsSource{
+		fallback: fallback,
+		options:  options,
+	}
+}
+
+// Lookup returns value of credential with name derived from passed key
+// if all fields should be looked up in credentials directory, or value
+// from fallback source otherwise.
+func (s *CredentialsSource) Lookup(key string) (string, bool) {
+	value, _, found, _ := s.LookupField(FieldInfo{Key: key})
+
+	return value, found
+}
+
+// Keys returns keys from fallback source along with uppercased names of
+// credentials.
+func (s *CredentialsSource) Keys() []string {
+	keys := s.fallback.Keys()
+
+	if s.options.Directory == "" {
+		return keys
+	}
+
+	entries, err := os.ReadDir(s.options.Directory)
+	if err != nil {
+		return keys
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			keys = append(keys, strings.ToUpper(entry.Name()))
+		}
+	}
+
+	return keys
+}
+
+// LookupField returns value of credential for field or value from
+// fallback source if credential wasn't found.
+func (s *CredentialsSource) LookupField(info FieldInfo) (string, Origin, bool, error) {
+	return s.LookupContext(context.Background(), info)
+}
+
+// LookupContext returns value of credential for field or value from
+// fallback source if credential wasn't found, passing context to
+// fallback source.
+func (s *CredentialsSource) LookupContext(ctx context.Context, info FieldInfo) (string, Origin, bool, error) {
+	name, isCredential := s.credentialName(info)
+
+	if isCredential && s.options.Directory != "" {
+		path := filepath.Join(s.options.Directory, name)
+
+		_, err := os.Stat(path)
+		if err == nil {
+			value, err := readValueFile(path, s.options.MaxFileSize)
+			if err != nil {
+				return "", Origin{}, false, err
+			}
+
+			printDebug("Value for '%s' was read from credential '%s'", info.Key, name)
+
+			return value, Origin{Source: "credentials", Key: name, File: path}, true, nil
+		}
+
+		printDebug("Credential '%s' for '%s' wasn't found, using fallback source", name, info.Key)
+	}
+
+	return lookupField(ctx, s.fallback, info)
+}
+
+// Returns credential name for field and flag indicating that field
+// should be looked up in credentials directory.
+func (s *CredentialsSource) credentialName(info FieldInfo) (string, bool) {
+	name, isCredential := parseTag(info.Tag.Get(tagName)).Get("credential")
+	if !isCredential && !s.options.All {
+		return "", false
+	}
+
+	if name == "" {
+		name = strings.ToLower(info.Key)
+	} else if info.Profile != "" {
+		// Key already contains profile suffix, but name from tag
+		// doesn't.
+		name += profileSeparator + strings.ToLower(info.Profile)
+	}
+
+	// Credential names can't contain path separators.
+	if strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return "", false
+	}
+
+	return name, true
+}
@@ -0,0 +1,206 @@
+package sec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Default maximum size of file with value.
+const defaultMaxFileSize = 1024 * 1024
+
+var (
+	errDirRead      = errors.New("failed to read directory")
+	errFileRead     = errors.New("failed to read file")
+	errFileTooLarge = errors.New("file is too large")
+)
+
+// DirOptions represents configuration for DirSource.
+type DirOptions struct {
+	// KeyMapper converts file name into key. If it returns empty
+	// string, then file will be ignored. By default file name will be
+	// uppercased with dashes and dots replaced with underscores, so
+	// "database-uri" file will provide "DATABASE_URI" key.
+	KeyMapper func(name string) string
+	// MaxFileSize is a maximum size of file in bytes. Larger files will
+	// produce an error. Defaults to 1 MiB.
+	MaxFileSize int64
+}
+
+// DirSource is a source which treats every file in directory as key and
+// it's content (with trailing new lines trimmed) as value. Suitable for
+// Kubernetes ConfigMaps and Secrets mounted as volumes and for Docker
+// secrets in /run/secrets. Hidden files (including Kubernetes' "..data"
+// symlinks) and directories are ignored, symlinks to files are followed.
+type DirSource struct {
+	path    string
+	options DirOptions
+	watch   *fileWatch
+
+	mutex  sync.RWMutex
+	values map[string]string
+	files  map[string]string
+}
+
+// NewDirSource reads every file in passed directory. If options are
+// nil - default ones will be used.
+func NewDirSource(path string, config *DirOptions) (*DirSource, error) {
+	options := DirOptions{}
+	if config != nil {
+		options = *config
+	}
+
+	if options.KeyMapper == nil {
+		options.KeyMapper = defaultDirKeyMapper
+	}
+
+	if options.MaxFileSize <= 0 {
+		options.MaxFileSize = defaultMaxFileSize
+	}
+
+	watch := newFileWatch(path)
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errDirRead, err.Error())
+	}
+
+	source := &DirSource{
+		path:    path,
+		options: options,
+		watch:   watch,
+		values:  make(map[string]string),
+		files:   make(map[string]string),
+	}
+
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		key := options.KeyMapper(entry.Name())
+		if key == "" {
+			continue
+		}
+
+		filePath := filepath.Join(path, entry.Name())
+
+		// Stat follows symlinks, which are used by Kubernetes.
+		info, err := os.Stat(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", errDirRead, err.Error())
+		}
+
+		if !info.Mode().IsRegular() {
+			continue
+		}
+
+		value, err := readValueFile(filePath, options.MaxFileSize)
+		if err != nil {
+			return nil, err
+		}
+
+		source.values[key] = value
+		source.files[key] = filePath
+	}
+
+	return source, nil
+}
+
+// Load reads directory again if any file in it was changed, added or
+// removed.
+func (s *DirSource) Load(ctx context.Context) error {
+	if !s.watch.changed() {
+		return nil
+	}
+
+	source, err := NewDirSource(s.path, &s.options)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	s.values = source.values
+	s.files = source.files
+	s.mutex.Unlock()
+
+	s.watch.update(source.watch)
+
+	return nil
+}
+
+// Changed checks if any file in directory was changed, added or removed
+// since last loading.
+func (s *DirSource) Changed(ctx context.Context) (bool, error) {
+	return s.watch.changed(), nil
+}
+
+// Lookup returns content of file mapped to passed key.
+func (s *DirSource) Lookup(key string) (string, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	value, found := s.values[key]
+
+	return value, found
+}
+
+// Keys returns keys for all read files.
+func (s *DirSource) Keys() []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	keys := make([]string, 0, len(s.values))
+
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// Origin returns path to file value was read from.
+func (s *DirSource) Origin(key string) (Origin, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	file, found := s.files[key]
+	if !found {
+		return Origin{}, false
+	}
+
+	return Origin{Source: "dir", Key: key, File: file}, true
+}
+
+// Converts file name into key.
+func defaultDirKeyMapper(name string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+}
+
+// Reads file with value, respecting maximum size. Trailing new lines
+// will be trimmed.
+func readValueFile(path string, maxSize int64) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", errFileRead, err.Error())
+	}
+
+	defer file.Close()
+
+	// One more byte is read to find out if file is larger than allowed.
+	data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", errFileRead, err.Error())
+	}
+
+	if int64(len(data)) > maxSize {
+		return "", fmt.Errorf("%w: '%s' is larger than %d bytes", errFileTooLarge, path, maxSize)
+	}
+
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
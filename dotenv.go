@@ -0,0 +1,373 @@
+package sec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+var (
+	errDotenvSyntax = errors.New("dotenv syntax error")
+	errDotenvRead   = errors.New("failed to read dotenv file")
+)
+
+// DotenvSource is a source which reads values from dotenv files.
+type DotenvSource struct {
+	files *fileWatch
+
+	mutex     sync.RWMutex
+	values    map[string]string
+	locations map[string]location
+}
+
+// This structure represents place in file where value was defined.
+type location struct {
+	// File is a path to file.
+	File string
+	// Line is a line number, starting from 1.
+	Line int
+}
+
+// NewDotenvSource reads passed dotenv files. Files are read in passed
+// order, so values from latter files will override values from earlier
+// ones. Supported syntax:
+//
+//	# Comments and empty lines are ignored.
+//	KEY=value # inline comments are allowed for unquoted values
+//	export KEY=value
+//	KEY='literal value, no escapes and references'
+//	KEY="escapes (\n, \t, \", \\, \$) and ${REFERENCES} are allowed
+//	in double quoted values, which also might be multi-line"
+//
+// References are resolved using values defined earlier in files and
+// then using operating system's environment. Usually this source should
+// be layered beneath real environment:
+//
+//	dotenv, err := sec.NewDotenvSource(".env")
+//	...
+//	err = sec.Parse(cfg, &sec.Options{Source: sec.Layers{dotenv, sec.EnvSource{}}})
+func NewDotenvSource(paths ...string) (*DotenvSource, error) {
+	source := &DotenvSource{
+		files:     newFileWatch(paths...),
+		values:    make(map[string]string),
+		locations: make(map[string]location),
+	}
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", errDotenvRead, err.Error())
+		}
+
+		p := &dotenvParser{
+			source: source,
+			path:   path,
+			lines:  strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n"),
+		}
+
+		err = p.parse()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return source, nil
+}
+
+// Load reads files again if they were changed.
+func (s *DotenvSource) Load(ctx context.Context) error {
+	if !s.files.changed() {
+		return nil
+	}
+
+	source, err := NewDotenvSource(s.files.paths...)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	s.values = source.values
+	s.locations = source.locations
+	s.mutex.Unlock()
+
+	s.files.update(source.files)
+
+	return nil
+}
+
+// Changed checks if files were changed since last loading.
+func (s *DotenvSource) Changed(ctx context.Context) (bool, error) {
+	return s.files.changed(), nil
+}
+
+// Lookup returns value defined in dotenv files.
+func (s *DotenvSource) Lookup(key string) (string, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	value, found := s.values[key]
+
+	return value, found
+}
+
+// Keys returns all keys defined in dotenv files.
+func (s *DotenvSource) Keys() []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	keys := make([]string, 0, len(s.values))
+
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// This structure holds state of single dotenv file parsing.
+type dotenvParser struct {
+	source *DotenvSource
+	path   string
+	lines  []string
+	// Index of currently parsed line.
+	idx int
+}
+
+// Parses every line of dotenv file.
+func (p *dotenvParser) parse() error {
+	for p.idx = 0; p.idx < len(p.lines); p.idx++ {
+		lineNumber := p.idx + 1
+
+		line := strings.TrimSpace(p.lines[p.idx])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "export ") || strings.HasPrefix(line, "export\t") {
+			line = strings.TrimSpace(line[len("export"):])
+		}
+
+		eqIdx := strings.Index(line, "=")
+		if eqIdx == -1 {
+			return p.syntaxError(lineNumber, "expected '=' after variable name")
+		}
+
+		key := strings.TrimSpace(line[:eqIdx])
+		if !isValidDotenvKey(key) {
+			return p.syntaxError(lineNumber, fmt.Sprintf("invalid variable name '%s'", key))
+		}
+
+		value, err := p.parseValue(strings.TrimLeft(line[eqIdx+1:], " \t"), lineNumber)
+		if err != nil {
+			return err
+		}
+
+		p.source.values[key] = value
+		p.source.locations[key] = location{File: p.path, Line: lineNumber}
+	}
+
+	return nil
+}
+
+// Parses value, which starts on passed line.
+func (p *dotenvParser) parseValue(raw string, lineNumber int) (string, error) {
+	switch {
+	case strings.HasPrefix(raw, "'"):
+		endIdx := strings.Index(raw[1:], "'")
+		if endIdx == -1 {
+			return "", p.syntaxError(lineNumber, "unterminated single quoted value")
+		}
+
+		err := p.checkTrailing(raw[endIdx+2:], lineNumber)
+		if err != nil {
+			return "", err
+		}
+
+		return raw[1 : endIdx+1], nil
+	case strings.HasPrefix(raw, "\""):
+		return p.parseDoubleQuoted(raw[1:], lineNumber)
+	default:
+		// Inline comment should be separated from value by whitespace.
+		for idx := 1; idx < len(raw); idx++ {
+			if raw[idx] == '#' && (raw[idx-1] == ' ' || raw[idx-1] == '\t') {
+				raw = raw[:idx]
+
+				break
+			}
+		}
+
+		if strings.HasPrefix(raw, "#") {
+			raw = ""
+		}
+
+		return p.expand(strings.TrimSpace(raw), lineNumber)
+	}
+}
+
+// Parses double quoted value, which might span several lines.
+func (p *dotenvParser) parseDoubleQuoted(raw string, lineNumber int) (string, error) {
+	var value strings.Builder
+
+	for {
+		for idx := 0; idx < len(raw); idx++ {
+			switch raw[idx] {
+			case '"':
+				err := p.checkTrailing(raw[idx+1:], p.idx+1)
+				if err != nil {
+					return "", err
+				}
+
+				return value.String(), nil
+			case '\\':
+				if idx+1 == len(raw) {
+					value.WriteByte('\\')
+
+					continue
+				}
+
+				idx++
+
+				switch raw[idx] {
+				case 'n':
+					value.WriteByte('\n')
+				case 'r':
+					value.WriteByte('\r')
+				case 't':
+					value.WriteByte('\t')
+				case '"', '\\', '$':
+					value.WriteByte(raw[idx])
+				default:
+					value.WriteByte('\\')
+					value.WriteByte(raw[idx])
+				}
+			case '$':
+				name, length, err := p.reference(raw[idx:], p.idx+1)
+				if err != nil {
+					return "", err
+				}
+
+				if length == 0 {
+					value.WriteByte('$')
+
+					continue
+				}
+
+				value.WriteString(p.resolve(name))
+
+				idx += length - 1
+			default:
+				value.WriteByte(raw[idx])
+			}
+		}
+
+		// Closing quote wasn't found on current line, value continues
+		// on next one.
+		p.idx++
+		if p.idx >= len(p.lines) {
+			return "", p.syntaxError(lineNumber, "unterminated double quoted value")
+		}
+
+		value.WriteByte('\n')
+
+		raw = p.lines[p.idx]
+	}
+}
+
+// Expands references in unquoted value.
+func (p *dotenvParser) expand(raw string, lineNumber int) (string, error) {
+	var value strings.Builder
+
+	for idx := 0; idx < len(raw); idx++ {
+		if raw[idx] != '$' {
+			value.WriteByte(raw[idx])
+
+			continue
+		}
+
+		name, length, err := p.reference(raw[idx:], lineNumber)
+		if err != nil {
+			return "", err
+		}
+
+		if length == 0 {
+			value.WriteByte('$')
+
+			continue
+		}
+
+		value.WriteString(p.resolve(name))
+
+		idx += length - 1
+	}
+
+	return value.String(), nil
+}
+
+// Reads "${NAME}" reference from beginning of passed string. Returns
+// variable name and reference length. Zero length means that there is
+// no reference and dollar sign should be used as is.
+func (p *dotenvParser) reference(raw string, lineNumber int) (string, int, error) {
+	if !strings.HasPrefix(raw, "${") {
+		return "", 0, nil
+	}
+
+	endIdx := strings.Index(raw, "}")
+	if endIdx == -1 {
+		return "", 0, p.syntaxError(lineNumber, "unterminated variable reference")
+	}
+
+	name := raw[2:endIdx]
+	if !isValidDotenvKey(name) {
+		return "", 0, p.syntaxError(lineNumber, fmt.Sprintf("invalid variable name '%s' in reference", name))
+	}
+
+	return name, endIdx + 1, nil
+}
+
+// Resolves referenced variable using previously defined values and
+// operating system's environment.
+func (p *dotenvParser) resolve(name string) string {
+	if value, found := p.source.values[name]; found {
+		return value
+	}
+
+	return os.Getenv(name)
+}
+
+// Checks that there is nothing except comment after closing quote.
+func (p *dotenvParser) checkTrailing(raw string, lineNumber int) error {
+	raw = strings.TrimSpace(raw)
+	if raw != "" && !strings.HasPrefix(raw, "#") {
+		return p.syntaxError(lineNumber, fmt.Sprintf("unexpected '%s' after closing quote", raw))
+	}
+
+	return nil
+}
+
+// Composes syntax error with file name and line number.
+func (p *dotenvParser) syntaxError(lineNumber int, reason string) error {
+	return fmt.Errorf("%w: %s:%d: %s", errDotenvSyntax, p.path, lineNumber, reason)
+}
+
+// Checks if passed string can be used as variable name.
+func isValidDotenvKey(key string) bool {
+	if key == "" {
+		return false
+	}
+
+	for idx, char := range key {
+		switch {
+		case char == '_', char == '.',
+			char >= 'a' && char <= 'z',
+			char >= 'A' && char <= 'Z':
+		case char >= '0' && char <= '9' && idx > 0:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,173 @@
+package sec
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// EncryptedPrefix is a prefix of encrypted values, which are followed by
+// base64-encoded nonce and AES-GCM sealed data.
+const EncryptedPrefix = "enc:v1:"
+
+var (
+	errDecrypt       = errors.New("failed to decrypt value")
+	errEncryptionKey = errors.New("invalid encryption key")
+	errNoKey         = errors.New("encryption key isn't set")
+)
+
+// GenerateKey returns new random base64-encoded 256-bit key suitable for
+// Encrypt() and DecryptionKeyVariable option.
+func GenerateKey() (string, error) {
+	key := make([]byte, 32)
+
+	_, err := io.ReadFull(rand.Reader, key)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// Encrypt encrypts value with base64-encoded AES key (16, 24 or 32
+// bytes long) using AES-GCM. Returned value has "enc:v1:" prefix and
+// can be put in any source.
+func Encrypt(key, value string) (string, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(value), nil)
+
+	return EncryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts value produced by Encrypt() with base64-encoded AES
+// key.
+func Decrypt(key, value string) (string, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+
+	return decrypt(aead, value)
+}
+
+// Decrypts value with passed cipher.
+func decrypt(aead cipher.AEAD, value string) (string, error) {
+	if !strings.HasPrefix(value, EncryptedPrefix) {
+		return "", fmt.Errorf("%w: value doesn't start with '%s'", errDecrypt, EncryptedPrefix)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, EncryptedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid base64: %s", errDecrypt, err.Error())
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("%w: value is too short", errDecrypt)
+	}
+
+	data, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("%w: wrong key or corrupted value", errDecrypt)
+	}
+
+	return string(data), nil
+}
+
+// Creates AES-GCM cipher from base64-encoded key.
+func newAEAD(key string) (cipher.AEAD, error) {
+	rawKey, err := base64.StdEncoding.DecodeString(strings.TrimSpace(key))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid base64: %s", errEncryptionKey, err.Error())
+	}
+
+	block, err := aes.NewCipher(rawKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errEncryptionKey, err.Error())
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// Decrypts field's value if it is encrypted. Decrypted fields are
+// treated as secrets.
+func (p *parser) decryptValue(element *field, data string) (string, error) {
+	if !strings.HasPrefix(data, EncryptedPrefix) {
+		return data, nil
+	}
+
+	element.Secret = true
+
+	aead, err := p.encryptionKey()
+	if err != nil {
+		return "", fmt.Errorf("%w (field '%s', variable '%s')", err, element.Path, element.EnvVar)
+	}
+
+	decrypted, err := decrypt(aead, data)
+	if err != nil {
+		return "", fmt.Errorf("%w (field '%s', variable '%s')", err, element.Path, element.EnvVar)
+	}
+
+	printDebug("Value for '%s' was decrypted", element.EnvVar)
+
+	return decrypted, nil
+}
+
+// Returns cipher for decryption. Key is read once per parsing from
+// variable defined by DecryptionKeyVariable option or from file pointed
+// by same variable with "_FILE" suffix.
+func (p *parser) encryptionKey() (cipher.AEAD, error) {
+	if p.aead != nil {
+		return p.aead, nil
+	}
+
+	name := p.options.DecryptionKeyVariable
+	if name == "" {
+		return nil, fmt.Errorf("%w: DecryptionKeyVariable option is empty", errNoKey)
+	}
+
+	key, found, err := p.lookupKey(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		path, fileFound, err := p.lookupKey(name + fileVariableSuffix)
+		if err != nil {
+			return nil, err
+		}
+
+		if !fileFound {
+			return nil, fmt.Errorf("%w: neither '%s' nor '%s' are set", errNoKey, name, name+fileVariableSuffix)
+		}
+
+		key, err = readValueFile(path, defaultMaxFileSize)
+		if err != nil {
+			return nil, fmt.Errorf("%w (path from '%s')", err, name+fileVariableSuffix)
+		}
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, fmt.Errorf("%w (key from '%s')", err, name)
+	}
+
+	p.aead = aead
+
+	return p.aead, nil
+}
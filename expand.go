@@ -0,0 +1,171 @@
+package sec
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	errExpandSyntax   = errors.New("invalid variable reference")
+	errExpandCycle    = errors.New("variable refers to itself")
+	errExpandRequired = errors.New("required variable isn't set")
+)
+
+// Expands references in value. Supported syntax:
+//
+//	${VAR}           - value of VAR or empty string.
+//	${VAR:-default}  - value of VAR or default if VAR is unset or empty.
+//	${VAR:?message}  - value of VAR or error with message if VAR is
+//	                   unset or empty.
+//	$$               - literal dollar sign.
+//
+// Referenced variables are taken from parser's source and expanded
+// too. If secret variable is referenced, then field becomes secret.
+// Stack contains names of variables that are currently expanded and
+// used to detect cycles.
+func (p *parser) expand(element *field, value string, stack []string) (string, error) {
+	var result strings.Builder
+
+	for idx := 0; idx < len(value); idx++ {
+		if value[idx] != '$' || idx+1 == len(value) {
+			result.WriteByte(value[idx])
+
+			continue
+		}
+
+		switch value[idx+1] {
+		case '$':
+			result.WriteByte('$')
+
+			idx++
+		case '{':
+			endIdx := matchingBrace(value, idx+2)
+			if endIdx == -1 {
+				return "", fmt.Errorf("%w: unterminated reference in '%s'", errExpandSyntax, stack[len(stack)-1])
+			}
+
+			expanded, err := p.expandReference(element, value[idx+2:endIdx], stack)
+			if err != nil {
+				return "", err
+			}
+
+			result.WriteString(expanded)
+
+			idx = endIdx
+		default:
+			result.WriteByte('$')
+		}
+	}
+
+	return result.String(), nil
+}
+
+// Expands single reference, which is a text between "${" and "}".
+func (p *parser) expandReference(element *field, reference string, stack []string) (string, error) {
+	name := reference
+	operator := ""
+	argument := ""
+
+	if opIdx := strings.Index(reference, ":"); opIdx != -1 {
+		name = reference[:opIdx]
+
+		if len(reference) < opIdx+2 || (reference[opIdx+1] != '-' && reference[opIdx+1] != '?') {
+			return "", fmt.Errorf("%w: '${%s}' in '%s', expected ':-' or ':?'", errExpandSyntax, reference, stack[len(stack)-1])
+		}
+
+		operator = reference[opIdx : opIdx+2]
+		argument = reference[opIdx+2:]
+	}
+
+	if name == "" {
+		return "", fmt.Errorf("%w: empty variable name in '%s'", errExpandSyntax, stack[len(stack)-1])
+	}
+
+	for idx, stackName := range stack {
+		if stackName == name {
+			return "", fmt.Errorf("%w: %s -> %s", errExpandCycle, strings.Join(stack[idx:], " -> "), name)
+		}
+	}
+
+	value, found, err := p.lookupKey(name)
+	if err != nil {
+		return "", err
+	}
+
+	if found && value != "" {
+		encrypted := strings.HasPrefix(value, EncryptedPrefix)
+
+		// Secrets shouldn't leak through fields that refer to them.
+		if !element.Secret && (encrypted || p.isSecretKey(name)) {
+			printDebug("Field '%s' refers to secret '%s' and will be treated as secret", element.Path, name)
+
+			element.Secret = true
+		}
+
+		if encrypted {
+			value, err = p.decryptReference(name, value, stack)
+			if err != nil {
+				return "", err
+			}
+		}
+
+		return p.expand(element, value, append(stack, name))
+	}
+
+	switch operator {
+	case ":-":
+		return p.expand(element, argument, stack)
+	case ":?":
+		message, err := p.expand(element, argument, stack)
+		if err != nil {
+			return "", err
+		}
+
+		if message == "" {
+			message = "not set"
+		}
+
+		return "", fmt.Errorf("%w: '%s' referenced in '%s': %s", errExpandRequired, name, stack[len(stack)-1], message)
+	}
+
+	return "", nil
+}
+
+// Decrypts value of referenced variable.
+func (p *parser) decryptReference(name, value string, stack []string) (string, error) {
+	aead, err := p.encryptionKey()
+	if err != nil {
+		return "", fmt.Errorf("%w (variable '%s' referenced in '%s')", err, name, stack[len(stack)-1])
+	}
+
+	decrypted, err := decrypt(aead, value)
+	if err != nil {
+		return "", fmt.Errorf("%w (variable '%s' referenced in '%s')", err, name, stack[len(stack)-1])
+	}
+
+	printDebug("Value of '%s' referenced in '%s' was decrypted", name, stack[len(stack)-1])
+
+	return decrypted, nil
+}
+
+// Returns index of brace closing reference which starts at passed
+// index (right after "${"), or -1 if there is no such brace.
+func matchingBrace(value string, start int) int {
+	depth := 1
+
+	for idx := start; idx < len(value); idx++ {
+		switch {
+		case value[idx] == '$' && idx+1 < len(value) && value[idx+1] == '{':
+			depth++
+			idx++
+		case value[idx] == '}':
+			depth--
+			if depth == 0 {
+				return idx
+			}
+		}
+	}
+
+	return -1
+}
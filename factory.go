@@ -0,0 +1,148 @@
+package sec
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Suffix of environment variable which contains name of implementation
+// that should be created for interface field.
+const discriminatorSuffix = "_TYPE"
+
+var (
+	errNotInterfacePointer   = errors.New("passed data is not a pointer to interface")
+	errFactoryExists         = errors.New("factory already registered")
+	errInvalidImplementation = errors.New("factory returned invalid implementation")
+	errUnknownImplementation = errors.New("unknown implementation")
+
+	// Registered factories for interfaces.
+	factories      = make(map[reflect.Type]map[string]Factory)
+	factoriesMutex sync.RWMutex
+)
+
+// Factory creates new implementation of interface. It should return
+// a pointer to structure which will be parsed as usual. Factories are
+// called while parsing and don't receive context, so they shouldn't do
+// I/O.
+type Factory func() interface{}
+
+// RegisterFactory registers factory for interface under passed name.
+// Interface should be passed as a pointer to it, like this:
+//
+//	sec.RegisterFactory((*StorageConfig)(nil), "s3", func() interface{} { return &S3Config{} })
+//
+// When field of such interface type will be found in passed to Parse()
+// structure SEC will read "<FIELD>_TYPE" environment variable, create
+// implementation using factory registered under this name and parse it
+// with field's prefix.
+func RegisterFactory(iface interface{}, name string, factory Factory) error {
+	typeOf := reflect.TypeOf(iface)
+	if typeOf == nil || typeOf.Kind() != reflect.Ptr || typeOf.Elem().Kind() != reflect.Interface {
+		return errNotInterfacePointer
+	}
+
+	typeOf = typeOf.Elem()
+
+	factoriesMutex.Lock()
+	defer factoriesMutex.Unlock()
+
+	if _, found := factories[typeOf]; !found {
+		factories[typeOf] = make(map[string]Factory)
+	}
+
+	if _, found := factories[typeOf][name]; found {
+		return fmt.Errorf("%w: '%s' for '%s'", errFactoryExists, name, typeOf.String())
+	}
+
+	factories[typeOf][name] = factory
+
+	return nil
+}
+
+// Removes all factories registered for interface. Interface should be
+// passed same way as to RegisterFactory().
+func unregisterFactories(iface interface{}) {
+	factoriesMutex.Lock()
+	defer factoriesMutex.Unlock()
+
+	delete(factories, reflect.TypeOf(iface).Elem())
+}
+
+// Returns copy of factories registered for interface, so lock isn't
+// held while factories are called. Returns nil if nothing was
+// registered.
+func registeredFactories(typeOf reflect.Type) map[string]Factory {
+	factoriesMutex.RLock()
+	defer factoriesMutex.RUnlock()
+
+	registered, found := factories[typeOf]
+	if !found {
+		return nil
+	}
+
+	implementations := make(map[string]Factory, len(registered))
+	for name, factory := range registered {
+		implementations[name] = factory
+	}
+
+	return implementations
+}
+
+// Creates implementation for interface field if factories were
+// registered for it and discriminator variable was set.
+func (p *parser) createImplementation(iface reflect.Value, envVar, path string) error {
+	implementations := registeredFactories(iface.Type())
+	if implementations == nil {
+		return nil
+	}
+
+	// Discriminator is reserved, fields can't use it.
+	discriminator := envVar + discriminatorSuffix
+	p.discriminators[discriminator] = path
+
+	name, found, err := p.lookupKey(discriminator)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		printDebug("Discriminator '%s' for field '%s' wasn't found, keeping current value", discriminator, path)
+
+		return nil
+	}
+
+	factory, found := implementations[name]
+	if !found {
+		names := make([]string, 0, len(implementations))
+		for implementationName := range implementations {
+			names = append(names, implementationName)
+		}
+
+		sort.Strings(names)
+
+		return fmt.Errorf("%w '%s' in '%s' for field '%s', known: %s",
+			errUnknownImplementation, name, discriminator, path, strings.Join(names, ", "))
+	}
+
+	if !iface.CanSet() {
+		printDebug("Field '%s' can't be set, implementation '%s' won't be created", path, name)
+
+		return nil
+	}
+
+	implementation := reflect.ValueOf(factory())
+	if implementation.Kind() != reflect.Ptr || !implementation.Type().Implements(iface.Type()) {
+		return fmt.Errorf("%w: '%s' for field '%s' should be a pointer implementing '%s'",
+			errInvalidImplementation, name, path, iface.Type().String())
+	}
+
+	printDebug("Implementation '%s' (%s) created for field '%s'", name, implementation.Type().String(), path)
+
+	iface.Set(implementation)
+
+	return nil
+}
@@ -9,10 +9,37 @@ import (
 type field struct {
 	// Name is a field name. Mostly for debugging purpose.
 	Name string
+	// Path is a full path to field from passed structure, like
+	// "Database.URI". Used for error reporting.
+	Path string
 	// EnvVar is a name of environment variable we will try to read.
 	EnvVar string
 	// Pointer is a pointer to field wrapped in reflect.Value.
 	Pointer reflect.Value
 	// Kind is a reflect.Kind value.
 	Kind reflect.Kind
+	// Tag contains options from field's tag.
+	Tag tagOptions
+	// StructTag is a raw field's tag.
+	StructTag reflect.StructTag
+	// Secret indicates that field's value should never be printed.
+	Secret bool
+}
+
+// Returns information about field for sources.
+func (f *field) info() FieldInfo {
+	return FieldInfo{
+		Path: f.Path,
+		Key:  f.EnvVar,
+		Tag:  f.StructTag,
+	}
+}
+
+// This structure represents value that was copied from interface{}
+// to make it addressable. It should be stored back after parsing.
+type interfaceValue struct {
+	// Interface is an interface{} field wrapped in reflect.Value.
+	Interface reflect.Value
+	// Value is a copy of value that was in interface.
+	Value reflect.Value
 }
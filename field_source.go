@@ -0,0 +1,101 @@
+package sec
+
+import (
+	"context"
+	"reflect"
+)
+
+// FieldInfo describes field value is looked up for.
+type FieldInfo struct {
+	// Path is a path to field, like "Database.Password".
+	Path string
+	// Key is a key (environment variable name) composed for field, like
+	// "DATABASE_PASSWORD".
+	Key string
+	// Tag is a field's tag.
+	Tag reflect.StructTag
+	// Profile is a name of active profile if profile specific value is
+	// looked up. Key already contains profile suffix in such case.
+	Profile string
+}
+
+// FieldSource is implemented by sources which need to know more about
+// field than it's key, e.g. options defined in field's tag. If source
+// implements this interface, then LookupField will be used instead of
+// Lookup while parsing.
+type FieldSource interface {
+	// LookupField returns value for passed field and it's origin.
+	// Third returned value indicates that value was found.
+	LookupField(info FieldInfo) (string, Origin, bool, error)
+}
+
+// ContextSource is implemented by sources which do I/O while looking up
+// values, like requests to remote storages. If source implements this
+// interface, then LookupContext will be used instead of LookupField and
+// Lookup while parsing, with context passed to ParseContext().
+type ContextSource interface {
+	// LookupContext returns value for passed field and it's origin.
+	// Third returned value indicates that value was found. Lookup
+	// should be aborted when context is done.
+	LookupContext(ctx context.Context, info FieldInfo) (string, Origin, bool, error)
+}
+
+// LookupField returns value for field from source with highest priority
+// that has it.
+func (s Layers) LookupField(info FieldInfo) (string, Origin, bool, error) {
+	return s.LookupContext(context.Background(), info)
+}
+
+// LookupContext returns value for field from source with highest
+// priority that has it, passing context to sources.
+func (s Layers) LookupContext(ctx context.Context, info FieldInfo) (string, Origin, bool, error) {
+	for idx := len(s) - 1; idx >= 0; idx-- {
+		value, origin, found, err := lookupField(ctx, s[idx], info)
+		if err != nil || found {
+			return value, origin, found, err
+		}
+	}
+
+	return "", Origin{}, false, nil
+}
+
+// LookupField returns value for field with source name replaced in
+// origin.
+func (s *namedSource) LookupField(info FieldInfo) (string, Origin, bool, error) {
+	return s.LookupContext(context.Background(), info)
+}
+
+// LookupContext returns value for field with source name replaced in
+// origin, passing context to wrapped source.
+func (s *namedSource) LookupContext(ctx context.Context, info FieldInfo) (string, Origin, bool, error) {
+	value, origin, found, err := lookupField(ctx, s.Source, info)
+	if found {
+		origin.Source = s.name
+	}
+
+	return value, origin, found, err
+}
+
+// Looks up value for field in source, using ContextSource or
+// FieldSource interfaces if source implements them.
+func lookupField(ctx context.Context, source Source, info FieldInfo) (string, Origin, bool, error) {
+	if contextSource, ok := source.(ContextSource); ok {
+		return contextSource.LookupContext(ctx, info)
+	}
+
+	if fieldSource, ok := source.(FieldSource); ok {
+		return fieldSource.LookupField(info)
+	}
+
+	value, found := source.Lookup(info.Key)
+	if !found {
+		return "", Origin{}, false, nil
+	}
+
+	origin, found := originOf(source, info.Key)
+	if !found {
+		origin = Origin{Source: sourceName(source), Key: info.Key}
+	}
+
+	return value, origin, true, nil
+}
@@ -0,0 +1,150 @@
+package sec
+
+import (
+	"context"
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ChangeDetector is implemented by sources which can tell if their
+// values were changed since last loading, like file sources which
+// compare files hashes. Used by Watch().
+type ChangeDetector interface {
+	// Changed returns true if values were changed since last loading.
+	// Values aren't updated, next parsing will load new ones.
+	Changed(ctx context.Context) (bool, error)
+}
+
+// Tracks state of files for detecting changes without inotify and
+// similar mechanisms.
+type fileWatch struct {
+	paths []string
+
+	mutex  sync.Mutex
+	states map[string]fileState
+}
+
+// State of file or directory.
+type fileState struct {
+	exists bool
+	hash   [sha256.Size]byte
+}
+
+// Creates new watch with current state of passed files. Should be
+// created before reading files, so changes made while reading will be
+// noticed.
+func newFileWatch(paths ...string) *fileWatch {
+	watch := &fileWatch{
+		paths:  paths,
+		states: make(map[string]fileState, len(paths)),
+	}
+
+	for _, path := range paths {
+		watch.states[path] = readFileState(path)
+	}
+
+	return watch
+}
+
+// Checks if any file was changed. Files are always hashed, as
+// modification time and size might stay same after quick writes.
+func (w *fileWatch) changed() bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for _, path := range w.paths {
+		previous := w.states[path]
+
+		state := readFileState(path)
+		if state.exists != previous.exists || state.hash != previous.hash {
+			printDebug("File '%s' was changed", path)
+
+			return true
+		}
+
+		w.states[path] = state
+	}
+
+	return false
+}
+
+// Replaces states with ones from passed watch, which was created while
+// reloading source.
+func (w *fileWatch) update(watch *fileWatch) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.states = watch.states
+}
+
+// Reads state of file. Directories are hashed with all files in them.
+func readFileState(path string) fileState {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}
+	}
+
+	state := fileState{exists: true}
+
+	hash := sha256.New()
+
+	if info.IsDir() {
+		entries, _ := os.ReadDir(path)
+
+		names := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			if !strings.HasPrefix(entry.Name(), ".") {
+				names = append(names, entry.Name())
+			}
+		}
+
+		sort.Strings(names)
+
+		for _, name := range names {
+			fileHash := readFileState(filepath.Join(path, name)).hash
+
+			_, _ = hash.Write([]byte(name + "\x00"))
+			_, _ = hash.Write(fileHash[:])
+		}
+	} else {
+		data, _ := os.ReadFile(path)
+		_, _ = hash.Write(data)
+	}
+
+	copy(state.hash[:], hash.Sum(nil))
+
+	return state
+}
+
+// Changed checks if any source was changed. Sources which can't detect
+// changes are treated as changed.
+func (s Layers) Changed(ctx context.Context) (bool, error) {
+	for _, source := range s {
+		changed, err := sourceChanged(ctx, source)
+		if err != nil || changed {
+			return changed, err
+		}
+	}
+
+	return false, nil
+}
+
+// Changed checks if wrapped source was changed.
+func (s *namedSource) Changed(ctx context.Context) (bool, error) {
+	return sourceChanged(ctx, s.Source)
+}
+
+// Checks if source was changed. Sources which can't detect changes are
+// treated as changed.
+func sourceChanged(ctx context.Context, source Source) (bool, error) {
+	detector, ok := source.(ChangeDetector)
+	if !ok {
+		return true, nil
+	}
+
+	return detector.Changed(ctx)
+}
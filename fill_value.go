@@ -4,18 +4,19 @@ import (
 	"fmt"
 	"reflect"
 	"strconv"
+	"strings"
 )
 
-func fillValue(element *field, data string) error {
+func (p *parser) fillValue(element *field, data string) error {
 	switch element.Kind {
 	case reflect.String:
 		element.Pointer.SetString(data)
 	case reflect.Bool:
 		val, err := strconv.ParseBool(data)
 		if err != nil {
-			printDebug("Error occurred while parsing boolean: %s", err.Error())
+			printDebug("Error occurred while parsing boolean: %s", element.displayError(err))
 
-			if options.ErrorsAreCritical {
+			if p.options.ErrorsAreCritical {
 				return errNotBool
 			}
 		}
@@ -27,9 +28,9 @@ func fillValue(element *field, data string) error {
 		// be 0 in case of configuration.
 		val, err := strconv.ParseInt(data, 10, 64)
 		if err != nil {
-			printDebug("Error occurred while parsing int: %s", err.Error())
+			printDebug("Error occurred while parsing int: %s", element.displayError(err))
 
-			if options.ErrorsAreCritical {
+			if p.options.ErrorsAreCritical {
 				return errNotInt
 			}
 		}
@@ -42,7 +43,7 @@ func fillValue(element *field, data string) error {
 			} else {
 				printDebug("Data in environment variable '%s' isn't int8", element.EnvVar)
 				element.Pointer.SetInt(0)
-				if options.ErrorsAreCritical {
+				if p.options.ErrorsAreCritical {
 					return errNotInt8
 				}
 			}
@@ -53,7 +54,7 @@ func fillValue(element *field, data string) error {
 			} else {
 				printDebug("Data in environment variable '%s' isn't int16", element.EnvVar)
 				element.Pointer.SetInt(0)
-				if options.ErrorsAreCritical {
+				if p.options.ErrorsAreCritical {
 					return errNotInt16
 				}
 			}
@@ -64,7 +65,7 @@ func fillValue(element *field, data string) error {
 			} else {
 				printDebug("Data in environment variable '%s' isn't int32", element.EnvVar)
 				element.Pointer.SetInt(0)
-				if options.ErrorsAreCritical {
+				if p.options.ErrorsAreCritical {
 					return errNotInt32
 				}
 			}
@@ -77,9 +78,9 @@ func fillValue(element *field, data string) error {
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 		val, err := strconv.ParseUint(data, 10, 64)
 		if err != nil {
-			printDebug("Error occurred while parsing unsigned integer: %s", err.Error())
+			printDebug("Error occurred while parsing unsigned integer: %s", element.displayError(err))
 
-			if options.ErrorsAreCritical {
+			if p.options.ErrorsAreCritical {
 				return errNotUint
 			}
 		}
@@ -92,7 +93,7 @@ func fillValue(element *field, data string) error {
 			} else {
 				printDebug("Data in environment variable '%s' isn't uint8", element.EnvVar)
 				element.Pointer.SetUint(0)
-				if options.ErrorsAreCritical {
+				if p.options.ErrorsAreCritical {
 					return errNotUint8
 				}
 			}
@@ -103,7 +104,7 @@ func fillValue(element *field, data string) error {
 			} else {
 				printDebug("Data in environment variable '%s' isn't uint16", element.EnvVar)
 				element.Pointer.SetUint(0)
-				if options.ErrorsAreCritical {
+				if p.options.ErrorsAreCritical {
 					return errNotUint16
 				}
 			}
@@ -114,7 +115,7 @@ func fillValue(element *field, data string) error {
 			} else {
 				printDebug("Data in environment variable '%s' isn't uint32", element.EnvVar)
 				element.Pointer.SetUint(0)
-				if options.ErrorsAreCritical {
+				if p.options.ErrorsAreCritical {
 					return errNotUint32
 				}
 			}
@@ -127,14 +128,49 @@ func fillValue(element *field, data string) error {
 	case reflect.Float32, reflect.Float64:
 		val, err := strconv.ParseFloat(data, 64)
 		if err != nil {
-			printDebug("Error occurred while parsing float: %s", err.Error())
+			printDebug("Error occurred while parsing float: %s", element.displayError(err))
 
-			if options.ErrorsAreCritical {
+			if p.options.ErrorsAreCritical {
 				return errNotFloat
 			}
 		}
 
 		element.Pointer.SetFloat(val)
+	case reflect.Struct:
+		// The only structure that can be filled is Secret.
+		if element.Pointer.Type() == secretType {
+			element.Pointer.Set(reflect.ValueOf(NewSecret(data)))
+		}
+	case reflect.Slice:
+		// Byte slices are filled with data as is.
+		if element.Pointer.Type().Elem().Kind() == reflect.Uint8 {
+			element.Pointer.SetBytes([]byte(data))
+
+			return nil
+		}
+
+		// Other slices are filled with comma-separated values which
+		// are parsed using same rules as for other fields.
+		items := splitList(data)
+		slice := reflect.MakeSlice(element.Pointer.Type(), len(items), len(items))
+
+		for idx, item := range items {
+			err := p.fillValue(&field{
+				Name:      element.Name,
+				Path:      element.Path,
+				EnvVar:    element.EnvVar,
+				Pointer:   slice.Index(idx),
+				Kind:      slice.Index(idx).Kind(),
+				Tag:       element.Tag,
+				StructTag: element.StructTag,
+				Secret:    element.Secret,
+			}, item)
+			if err != nil {
+				return err
+			}
+		}
+
+		element.Pointer.Set(slice)
 	case reflect.Interface:
 		// We should not attempt to work with data in interface{}
 		// unless it is a pointer to value.
@@ -143,7 +179,7 @@ func fillValue(element *field, data string) error {
 				"into interface{}. Nothing will be done with this element.",
 				element.EnvVar)
 
-			if options.ErrorsAreCritical {
+			if p.options.ErrorsAreCritical {
 				// Better to say to user what is wrong instead of fighting with linters, so:
 				// nolint
 				return fmt.Errorf("element for environment variable '%s' isn't a pointer and put into interface", element.EnvVar)
@@ -157,8 +193,23 @@ func fillValue(element *field, data string) error {
 		element.Pointer = element.Pointer.Elem().Elem()
 		element.Kind = element.Pointer.Kind()
 
-		return fillValue(element, data)
+		return p.fillValue(element, data)
 	}
 
 	return nil
 }
+
+// Splits comma-separated list into trimmed items. Empty string produces
+// empty list.
+func splitList(data string) []string {
+	if strings.TrimSpace(data) == "" {
+		return []string{}
+	}
+
+	items := strings.Split(data, ",")
+	for idx := range items {
+		items[idx] = strings.TrimSpace(items[idx])
+	}
+
+	return items
+}
@@ -0,0 +1,120 @@
+package sec
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Holder owns current configuration and allows to read it from many
+// goroutines while it is reloaded. Configuration is never modified in
+// place: every reload parses and validates new structure and then
+// replaces pointer to current one atomically, so readers always get
+// consistent snapshot without locks.
+type Holder struct {
+	current atomic.Value
+	watcher *watcher
+
+	subscribersMutex sync.Mutex
+	subscribers      map[int]func(update *Update)
+	lastSubscriberID int
+}
+
+// NewHolder parses configuration into passed structure, which becomes
+// current configuration. Options are used for all reloads, Interval is
+// used by Watch(), OnError receives errors of reloads started by
+// Watch() and ReloadOnSignal().
+func NewHolder(ctx context.Context, structure interface{}, config *WatchOptions) (*Holder, error) {
+	h := &Holder{
+		subscribers: make(map[int]func(update *Update)),
+	}
+
+	w, err := newWatcher(ctx, structure, config, h.publish)
+	if err != nil {
+		return nil, err
+	}
+
+	h.watcher = w
+	h.current.Store(structure)
+
+	return h, nil
+}
+
+// Get returns pointer to current configuration, which has same type as
+// structure passed to NewHolder(). It should not be modified. Get
+// should be called every time fresh configuration is needed, e.g. once
+// per request, and returned value should be used for all reads to get
+// consistent values.
+func (h *Holder) Get() interface{} {
+	return h.current.Load()
+}
+
+// Reload parses and validates configuration and makes it current if it
+// was changed. Returns update if configuration was changed or nil
+// otherwise. If parsing or validation failed, error is returned and
+// current configuration remains in use.
+func (h *Holder) Reload(ctx context.Context) (*Update, error) {
+	h.watcher.mutex.Lock()
+	defer h.watcher.mutex.Unlock()
+
+	return h.watcher.reload(ctx)
+}
+
+// Watch reloads configuration when sources are changed until context is
+// done, like Watch() function.
+func (h *Holder) Watch(ctx context.Context) {
+	go h.watcher.run(ctx)
+}
+
+// ReloadOnSignal reloads configuration on SIGHUP until context is done,
+// like ReloadOnSignal() function.
+func (h *Holder) ReloadOnSignal(ctx context.Context) {
+	h.watcher.runOnSignals(ctx)
+}
+
+// Subscribe adds callback which will be called after every change of
+// current configuration. Callbacks are called one by one in
+// subscription order and should not call Reload(). Returned function
+// removes subscription.
+func (h *Holder) Subscribe(callback func(update *Update)) func() {
+	h.subscribersMutex.Lock()
+	defer h.subscribersMutex.Unlock()
+
+	h.lastSubscriberID++
+	id := h.lastSubscriberID
+
+	h.subscribers[id] = callback
+
+	return func() {
+		h.subscribersMutex.Lock()
+		defer h.subscribersMutex.Unlock()
+
+		delete(h.subscribers, id)
+	}
+}
+
+// Makes updated configuration current and notifies subscribers.
+func (h *Holder) publish(update *Update) {
+	h.current.Store(update.Config)
+
+	h.subscribersMutex.Lock()
+
+	ids := make([]int, 0, len(h.subscribers))
+	for id := range h.subscribers {
+		ids = append(ids, id)
+	}
+
+	sort.Ints(ids)
+
+	callbacks := make([]func(update *Update), 0, len(ids))
+	for _, id := range ids {
+		callbacks = append(callbacks, h.subscribers[id])
+	}
+
+	h.subscribersMutex.Unlock()
+
+	for _, callback := range callbacks {
+		callback(update)
+	}
+}
@@ -0,0 +1,216 @@
+// nolint:exhaustruct
+package sec
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHolder(t *testing.T) {
+	t.Parallel()
+
+	path := writeTestFile(t, ".env", "HOST=localhost\nPORT=8080\nPASSWORD=first\n")
+
+	dotenv, err := NewDotenvSource(path)
+	require.Nil(t, err)
+
+	holder, err := NewHolder(context.Background(), &testWatchedStruct{}, &WatchOptions{
+		Options:  &Options{Source: dotenv},
+		Interval: 10 * time.Millisecond,
+	})
+	require.Nil(t, err)
+
+	initial, ok := holder.Get().(*testWatchedStruct)
+	require.True(t, ok)
+	require.Equal(t, 8080, initial.Port)
+
+	var (
+		firstUpdates  []*Update
+		secondUpdates []*Update
+		mutex         sync.Mutex
+	)
+
+	holder.Subscribe(func(update *Update) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		firstUpdates = append(firstUpdates, update)
+	})
+
+	unsubscribe := holder.Subscribe(func(update *Update) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		secondUpdates = append(secondUpdates, update)
+	})
+
+	// Readers should always get consistent configuration while it is
+	// reloaded.
+	stop := make(chan struct{})
+
+	var readers sync.WaitGroup
+
+	for idx := 0; idx < 4; idx++ {
+		readers.Add(1)
+
+		go func() {
+			defer readers.Done()
+
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				// nolint:forcetypeassert
+				cfg := holder.Get().(*testWatchedStruct)
+				if (cfg.Port == 8080) != (cfg.Host == "localhost") {
+					panic("inconsistent configuration")
+				}
+			}
+		}()
+	}
+
+	// Nothing was changed.
+	update, err := holder.Reload(context.Background())
+	require.Nil(t, err)
+	require.Nil(t, update)
+
+	replaceTestFile(t, path, []byte("HOST=example.com\nPORT=9090\nPASSWORD=first\n"))
+
+	update, err = holder.Reload(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, []string{"Host", "Port"}, update.Changed)
+	require.Equal(t, update.Config, holder.Get())
+
+	// Previous configuration should not be modified.
+	require.Equal(t, 8080, initial.Port)
+	require.Equal(t, "localhost", initial.Host)
+
+	// Invalid configuration should be rejected.
+	unsubscribe()
+	replaceTestFile(t, path, []byte("HOST=localhost\nPORT=0\n"))
+
+	_, err = holder.Reload(context.Background())
+	require.EqualError(t, err, "port should be set")
+	require.Equal(t, update.Config, holder.Get())
+
+	// Changes should be detected when watching.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updates := make(chan *Update, 1)
+
+	holder.Subscribe(func(update *Update) {
+		updates <- update
+	})
+
+	holder.Watch(ctx)
+
+	replaceTestFile(t, path, []byte("HOST=localhost\nPORT=8080\nPASSWORD=second\n"))
+
+	update = receiveUpdate(t, updates)
+	require.Equal(t, update.Config, holder.Get())
+	// nolint:forcetypeassert
+	require.Equal(t, "second", holder.Get().(*testWatchedStruct).Password.Reveal())
+
+	close(stop)
+	readers.Wait()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	require.Len(t, firstUpdates, 2)
+	require.Len(t, secondUpdates, 1)
+	require.Equal(t, []string{"Host", "Password", "Port"}, firstUpdates[1].Changed)
+}
+
+func TestHolderUnsetSecrets(t *testing.T) {
+	t.Setenv("HOST", "localhost")
+	t.Setenv("PORT", "8080")
+	t.Setenv("PASSWORD", "password")
+
+	holder, err := NewHolder(context.Background(), &testWatchedStruct{}, &WatchOptions{
+		Options: &Options{UnsetSecrets: true},
+	})
+	require.Nil(t, err)
+
+	_, found := os.LookupEnv("PASSWORD")
+	require.False(t, found)
+
+	// Unset variables should be used on reload, so nothing is changed.
+	update, err := holder.Reload(context.Background())
+	require.Nil(t, err)
+	require.Nil(t, update)
+
+	// nolint:forcetypeassert
+	require.Equal(t, "password", holder.Get().(*testWatchedStruct).Password.Reveal())
+
+	t.Setenv("PORT", "9090")
+
+	update, err = holder.Reload(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, []string{"Port"}, update.Changed)
+	// nolint:forcetypeassert
+	require.Equal(t, "password", update.Config.(*testWatchedStruct).Password.Reveal())
+}
+
+// Source which detects changes until context is done, like blocking
+// queries to key/value storage.
+type testBlockingDetector struct {
+	MapSource
+	checking chan struct{}
+}
+
+func (s *testBlockingDetector) Changed(ctx context.Context) (bool, error) {
+	select {
+	case s.checking <- struct{}{}:
+	default:
+	}
+
+	<-ctx.Done()
+
+	return false, ctx.Err()
+}
+
+func TestHolderReloadWhileDetectingChanges(t *testing.T) {
+	t.Parallel()
+
+	source := &testBlockingDetector{MapSource: MapSource{"PORT": "8080"}, checking: make(chan struct{})}
+
+	holder, err := NewHolder(context.Background(), &testWatchedStruct{}, &WatchOptions{
+		Options:  &Options{Source: source},
+		Interval: 10 * time.Millisecond,
+	})
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	holder.Watch(ctx)
+
+	select {
+	case <-source.checking:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "changes weren't detected")
+	}
+
+	// Forced reload shouldn't wait for changes detection.
+	source.MapSource["PORT"] = "9090"
+
+	reloaded := make(chan *Update, 1)
+
+	go func() {
+		update, _ := holder.Reload(context.Background())
+		reloaded <- update
+	}()
+
+	update := receiveUpdate(t, reloaded)
+	require.Equal(t, []string{"Port"}, update.Changed)
+}
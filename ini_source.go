@@ -0,0 +1,460 @@
+package sec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+var (
+	errINISyntax = errors.New("INI syntax error")
+	errINIRead   = errors.New("failed to read INI file")
+
+	// Returned while parsing array which continues on next line.
+	errINIUnterminatedArray = errors.New("unterminated array")
+)
+
+// INISource is a source which reads values from INI (or TOML-like)
+// file. Sections are mapped onto nested structures and keys onto
+// fields, so this file:
+//
+//	timeout = 10
+//
+//	[database]
+//	uri = "postgres://localhost/app"
+//
+//	[database.replica]
+//	hosts = ["first", "second"]
+//
+// will provide "TIMEOUT", "DATABASE_URI" and "DATABASE_REPLICA_HOSTS"
+// keys. Sections like "[profiles.production.database]" contain profiles
+// specific values, e.g. "DATABASE_URI__PRODUCTION" key. Comments start
+// with "#" or ";". Values might be unquoted, single quoted (literal) or
+// double quoted (with \n, \r, \t, \", \\ escapes). Arrays (which might
+// span several lines) are joined using commas and can be used for
+// slice fields, so their items can't contain commas.
+type INISource struct {
+	files *fileWatch
+
+	mutex     sync.RWMutex
+	values    map[string]string
+	locations map[string]location
+}
+
+// NewINISource reads passed INI file.
+func NewINISource(path string) (*INISource, error) {
+	files := newFileWatch(path)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errINIRead, err.Error())
+	}
+
+	source := &INISource{
+		files:     files,
+		values:    make(map[string]string),
+		locations: make(map[string]location),
+	}
+
+	p := &iniParser{
+		source: source,
+		path:   path,
+		lines:  strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n"),
+	}
+
+	err = p.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	return source, nil
+}
+
+// Load reads file again if it was changed.
+func (s *INISource) Load(ctx context.Context) error {
+	if !s.files.changed() {
+		return nil
+	}
+
+	source, err := NewINISource(s.files.paths[0])
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	s.values = source.values
+	s.locations = source.locations
+	s.mutex.Unlock()
+
+	s.files.update(source.files)
+
+	return nil
+}
+
+// Changed checks if file was changed since last loading.
+func (s *INISource) Changed(ctx context.Context) (bool, error) {
+	return s.files.changed(), nil
+}
+
+// Lookup returns value from INI file.
+func (s *INISource) Lookup(key string) (string, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	value, found := s.values[key]
+
+	return value, found
+}
+
+// Keys returns all keys from INI file.
+func (s *INISource) Keys() []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	keys := make([]string, 0, len(s.values))
+
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// Origin returns file and line value was defined at.
+func (s *INISource) Origin(key string) (Origin, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	loc, found := s.locations[key]
+	if !found {
+		return Origin{}, false
+	}
+
+	return Origin{Source: "ini", Key: key, File: loc.File, Line: loc.Line}, true
+}
+
+// This structure holds state of INI file parsing.
+type iniParser struct {
+	source *INISource
+	path   string
+	lines  []string
+	// Prefix for keys in current section.
+	prefix string
+	// Suffix for keys in current section, used for profiles sections.
+	suffix string
+}
+
+// Parses every line of INI file.
+func (p *iniParser) parse() error {
+	for idx := 0; idx < len(p.lines); idx++ {
+		lineNumber := idx + 1
+
+		line := strings.TrimSpace(p.lines[idx])
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		if line[0] == '[' {
+			err := p.parseSection(line, lineNumber)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		eqIdx := strings.Index(line, "=")
+		if eqIdx == -1 {
+			return p.syntaxError(lineNumber, "expected '=' after key")
+		}
+
+		key, err := p.name(line[:eqIdx], lineNumber)
+		if err != nil {
+			return err
+		}
+
+		raw := strings.TrimSpace(line[eqIdx+1:])
+
+		value, err := p.parseValue(raw, lineNumber)
+
+		// Arrays might continue on next lines.
+		for errors.Is(err, errINIUnterminatedArray) {
+			idx++
+			if idx >= len(p.lines) {
+				return p.syntaxError(lineNumber, "unterminated array")
+			}
+
+			raw += "\n" + p.lines[idx]
+			value, err = p.parseValue(raw, lineNumber)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		p.source.values[p.prefix+key+p.suffix] = value
+		p.source.locations[p.prefix+key+p.suffix] = location{File: p.path, Line: lineNumber}
+	}
+
+	return nil
+}
+
+// Parses section header and sets prefix for following keys.
+func (p *iniParser) parseSection(line string, lineNumber int) error {
+	endIdx := strings.Index(line, "]")
+	if endIdx == -1 {
+		return p.syntaxError(lineNumber, "unterminated section name")
+	}
+
+	rest := strings.TrimSpace(line[endIdx+1:])
+	if rest != "" && rest[0] != '#' && rest[0] != ';' {
+		return p.syntaxError(lineNumber, fmt.Sprintf("unexpected '%s' after section name", rest))
+	}
+
+	name, err := p.name(line[1:endIdx], lineNumber)
+	if err != nil {
+		return err
+	}
+
+	p.prefix = name + "_"
+	p.suffix = ""
+
+	// Profiles sections, like "[profiles.production.database]", contain
+	// profiles specific values.
+	parts := strings.SplitN(line[1:endIdx], ".", 3)
+	if strings.ToUpper(strings.TrimSpace(parts[0])) == profilesSection {
+		if len(parts) < 2 {
+			return p.syntaxError(lineNumber, "profile name expected in profiles section")
+		}
+
+		profile, err := p.name(parts[1], lineNumber)
+		if err != nil {
+			return err
+		}
+
+		p.prefix = ""
+		p.suffix = profileKey("", profile)
+
+		if len(parts) == 3 {
+			sectionName, err := p.name(parts[2], lineNumber)
+			if err != nil {
+				return err
+			}
+
+			p.prefix = sectionName + "_"
+		}
+	}
+
+	return nil
+}
+
+// Converts dotted key or section name into environment variable name.
+func (p *iniParser) name(raw string, lineNumber int) (string, error) {
+	parts := strings.Split(strings.TrimSpace(raw), ".")
+
+	for idx, part := range parts {
+		part = strings.TrimSpace(part)
+		if !isValidININame(part) {
+			return "", p.syntaxError(lineNumber, fmt.Sprintf("invalid name '%s'", strings.TrimSpace(raw)))
+		}
+
+		parts[idx] = strings.ToUpper(part)
+	}
+
+	return strings.Join(parts, "_"), nil
+}
+
+// Parses value. Arrays are joined using commas, so items can't contain
+// commas.
+func (p *iniParser) parseValue(raw string, lineNumber int) (string, error) {
+	if !strings.HasPrefix(raw, "[") {
+		value, rest, err := p.parseScalar(raw, lineNumber, false)
+		if err != nil {
+			return "", err
+		}
+
+		return value, p.checkTrailing(rest, lineNumber)
+	}
+
+	items := make([]string, 0)
+	rest := raw[1:]
+
+	for {
+		rest = skipINIWhitespace(rest)
+
+		if rest == "" {
+			return "", errINIUnterminatedArray
+		}
+
+		if rest[0] == ']' {
+			return strings.Join(items, ","), p.checkTrailing(rest[1:], lineNumber)
+		}
+
+		item, itemRest, err := p.parseScalar(rest, lineNumber, true)
+		if err != nil {
+			return "", err
+		}
+
+		// Items are joined using commas, so commas inside items can't
+		// be told apart from separators. Item isn't printed, as it
+		// might be a secret.
+		if strings.Contains(item, ",") {
+			return "", p.syntaxError(lineNumber, "array item contains comma")
+		}
+
+		items = append(items, item)
+		rest = skipINIWhitespace(itemRest)
+
+		switch {
+		case rest == "":
+			return "", errINIUnterminatedArray
+		case rest[0] == ',':
+			rest = rest[1:]
+		case rest[0] != ']':
+			return "", p.syntaxError(lineNumber, fmt.Sprintf("expected ',' or ']' in array, got '%s'", firstLine(rest)))
+		}
+	}
+}
+
+// Parses single value from beginning of passed string. Returns value
+// and rest of string.
+func (p *iniParser) parseScalar(raw string, lineNumber int, inArray bool) (string, string, error) {
+	switch {
+	case strings.HasPrefix(raw, "'"):
+		endIdx := strings.Index(raw[1:], "'")
+		if endIdx == -1 || strings.Contains(raw[1:endIdx+1], "\n") {
+			return "", "", p.syntaxError(lineNumber, "unterminated single quoted value")
+		}
+
+		return raw[1 : endIdx+1], raw[endIdx+2:], nil
+	case strings.HasPrefix(raw, "\""):
+		var value strings.Builder
+
+		for idx := 1; idx < len(raw); idx++ {
+			switch raw[idx] {
+			case '\n':
+				return "", "", p.syntaxError(lineNumber, "unterminated double quoted value")
+			case '"':
+				return value.String(), raw[idx+1:], nil
+			case '\\':
+				if idx+1 == len(raw) {
+					return "", "", p.syntaxError(lineNumber, "unterminated double quoted value")
+				}
+
+				idx++
+
+				switch raw[idx] {
+				case 'n':
+					value.WriteByte('\n')
+				case 'r':
+					value.WriteByte('\r')
+				case 't':
+					value.WriteByte('\t')
+				case '"', '\\':
+					value.WriteByte(raw[idx])
+				default:
+					return "", "", p.syntaxError(lineNumber, fmt.Sprintf("invalid escape sequence '\\%c'", raw[idx]))
+				}
+			default:
+				value.WriteByte(raw[idx])
+			}
+		}
+
+		return "", "", p.syntaxError(lineNumber, "unterminated double quoted value")
+	default:
+		// Unquoted value ends at comment, or at item separator or
+		// whitespace if it is an array item.
+		endIdx := len(raw)
+		if inArray {
+			if separatorIdx := strings.IndexAny(raw, ",] \t\n"); separatorIdx != -1 {
+				endIdx = separatorIdx
+			}
+		}
+
+		if commentIdx := iniCommentStart(raw[:endIdx]); commentIdx != -1 {
+			endIdx = commentIdx
+		}
+
+		return strings.TrimSpace(raw[:endIdx]), raw[endIdx:], nil
+	}
+}
+
+// Checks that there is nothing except comment after value.
+func (p *iniParser) checkTrailing(raw string, lineNumber int) error {
+	raw = strings.TrimSpace(raw)
+	if raw != "" && raw[0] != '#' && raw[0] != ';' {
+		return p.syntaxError(lineNumber, fmt.Sprintf("unexpected '%s' after value", firstLine(raw)))
+	}
+
+	return nil
+}
+
+// Composes syntax error with file name and line number.
+func (p *iniParser) syntaxError(lineNumber int, reason string) error {
+	return fmt.Errorf("%w: %s:%d: %s", errINISyntax, p.path, lineNumber, reason)
+}
+
+// Skips whitespace, new lines and comments till end of line.
+func skipINIWhitespace(raw string) string {
+	for raw != "" {
+		switch raw[0] {
+		case ' ', '\t', '\n':
+			raw = raw[1:]
+		case '#', ';':
+			newLineIdx := strings.Index(raw, "\n")
+			if newLineIdx == -1 {
+				return ""
+			}
+
+			raw = raw[newLineIdx:]
+		default:
+			return raw
+		}
+	}
+
+	return raw
+}
+
+// Returns index of comment start in passed string or -1 if there is no
+// comment. Comment should be separated from value with whitespace.
+func iniCommentStart(raw string) int {
+	for idx := 0; idx < len(raw); idx++ {
+		if (raw[idx] == '#' || raw[idx] == ';') && (idx == 0 || raw[idx-1] == ' ' || raw[idx-1] == '\t') {
+			return idx
+		}
+	}
+
+	return -1
+}
+
+// Returns first line of passed string.
+func firstLine(raw string) string {
+	if newLineIdx := strings.Index(raw, "\n"); newLineIdx != -1 {
+		return raw[:newLineIdx]
+	}
+
+	return raw
+}
+
+// Checks if passed string can be used as key or section name.
+func isValidININame(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for _, char := range name {
+		switch {
+		case char == '_', char == '-',
+			char >= 'a' && char <= 'z',
+			char >= 'A' && char <= 'Z',
+			char >= '0' && char <= '9':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,271 @@
+package sec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	errJSONRead    = errors.New("failed to read JSON file")
+	errJSONSyntax  = errors.New("JSON syntax error")
+	errJSONNotRoot = errors.New("JSON file should contain an object")
+	errJSONComma   = errors.New("JSON array item contains comma")
+)
+
+// JSONSource is a source which reads values from JSON file. Nested
+// objects are mapped onto nested structures same way as environment
+// variables names are composed, so this file:
+//
+//	{"database": {"uri": "postgres://localhost/app"}, "timeout": 10}
+//
+// will provide "DATABASE_URI" and "TIMEOUT" keys. Values are converted
+// using same rules as environment variables values. Arrays are joined
+// using commas, so looking up arrays with items containing commas (like
+// arrays of objects) produces an error. Nulls are treated as absent
+// values. Root "profiles" object might contain profiles specific values:
+//
+//	{"profiles": {"production": {"database": {"uri": "postgres://db/app"}}}}
+//
+// will provide "DATABASE_URI__PRODUCTION" key.
+type JSONSource struct {
+	path  string
+	files *fileWatch
+
+	mutex    sync.RWMutex
+	values   map[string]string
+	pointers map[string]string
+	// Errors for keys which values can't be represented.
+	invalid map[string]error
+}
+
+// NewJSONSource reads passed JSON file.
+func NewJSONSource(path string) (*JSONSource, error) {
+	files := newFileWatch(path)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errJSONRead, err.Error())
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var root interface{}
+
+	err = decoder.Decode(&root)
+	if err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			line := bytes.Count(data[:syntaxErr.Offset], []byte("\n")) + 1
+
+			return nil, fmt.Errorf("%w: %s:%d: %s", errJSONSyntax, path, line, syntaxErr.Error())
+		}
+
+		return nil, fmt.Errorf("%w: %s: %s", errJSONSyntax, path, err.Error())
+	}
+
+	object, isObject := root.(map[string]interface{})
+	if !isObject {
+		return nil, fmt.Errorf("%w: %s", errJSONNotRoot, path)
+	}
+
+	source := &JSONSource{
+		path:     path,
+		files:    files,
+		values:   make(map[string]string),
+		pointers: make(map[string]string),
+		invalid:  make(map[string]error),
+	}
+
+	source.flatten(object, "", "", "")
+
+	return source, nil
+}
+
+// Load reads file again if it was changed.
+func (s *JSONSource) Load(ctx context.Context) error {
+	if !s.files.changed() {
+		return nil
+	}
+
+	source, err := NewJSONSource(s.path)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	s.values = source.values
+	s.pointers = source.pointers
+	s.invalid = source.invalid
+	s.mutex.Unlock()
+
+	s.files.update(source.files)
+
+	return nil
+}
+
+// Changed checks if file was changed since last loading.
+func (s *JSONSource) Changed(ctx context.Context) (bool, error) {
+	return s.files.changed(), nil
+}
+
+// Lookup returns value from JSON file.
+func (s *JSONSource) Lookup(key string) (string, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	value, found := s.values[key]
+
+	return value, found
+}
+
+// LookupField returns value from JSON file for field. Error is returned
+// if value can't be represented, like array with items containing
+// commas.
+func (s *JSONSource) LookupField(info FieldInfo) (string, Origin, bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if err, invalid := s.invalid[info.Key]; invalid {
+		return "", Origin{}, false, err
+	}
+
+	value, found := s.values[info.Key]
+	if !found {
+		return "", Origin{}, false, nil
+	}
+
+	return value, Origin{Source: "json", Key: info.Key, File: s.path, Pointer: s.pointers[info.Key]}, true, nil
+}
+
+// Keys returns all keys from JSON file.
+func (s *JSONSource) Keys() []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	keys := make([]string, 0, len(s.values))
+
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// Origin returns JSON pointer to value.
+func (s *JSONSource) Origin(key string) (Origin, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	pointer, found := s.pointers[key]
+	if !found {
+		return Origin{}, false
+	}
+
+	return Origin{Source: "json", Key: key, File: s.path, Pointer: pointer}, true
+}
+
+// Maps object's members onto keys. Objects are also stored as values
+// (in JSON representation) so their usage for non-structure fields
+// will produce conversion errors.
+func (s *JSONSource) flatten(object map[string]interface{}, prefix, suffix, pointer string) {
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+
+	// Keys might collide after uppercasing, so order should be stable.
+	sort.Strings(names)
+
+	for _, name := range names {
+		key := prefix + strings.ToUpper(name)
+		memberPointer := pointer + "/" + strings.ReplaceAll(strings.ReplaceAll(name, "~", "~0"), "/", "~1")
+
+		switch value := object[name].(type) {
+		case nil:
+			continue
+		case map[string]interface{}:
+			// Root "profiles" object contains profiles specific values.
+			if key == profilesSection && prefix == "" && suffix == "" {
+				s.flattenProfiles(value, memberPointer)
+
+				continue
+			}
+
+			s.flatten(value, key+"_", suffix, memberPointer)
+
+			s.set(key+suffix, jsonString(value), memberPointer)
+		case []interface{}:
+			s.flattenArray(value, key+suffix, memberPointer)
+		default:
+			s.set(key+suffix, jsonString(value), memberPointer)
+		}
+	}
+}
+
+// Joins array's items using commas. Commas inside items can't be told
+// apart from separators, so such arrays are remembered as invalid and
+// error is reported only if they are looked up.
+func (s *JSONSource) flattenArray(array []interface{}, key, pointer string) {
+	items := make([]string, 0, len(array))
+
+	for idx, item := range array {
+		data := jsonString(item)
+		if strings.Contains(data, ",") {
+			s.invalid[key] = fmt.Errorf("%w: %s#%s/%d", errJSONComma, s.path, pointer, idx)
+
+			delete(s.values, key)
+			delete(s.pointers, key)
+
+			return
+		}
+
+		items = append(items, data)
+	}
+
+	s.set(key, strings.Join(items, ","), pointer)
+}
+
+// Maps profiles specific values onto keys with profile suffix, like
+// "DATABASE_URI__PRODUCTION".
+func (s *JSONSource) flattenProfiles(profiles map[string]interface{}, pointer string) {
+	for profile, values := range profiles {
+		object, isObject := values.(map[string]interface{})
+		if !isObject {
+			continue
+		}
+
+		profilePointer := pointer + "/" + strings.ReplaceAll(strings.ReplaceAll(profile, "~", "~0"), "/", "~1")
+
+		s.flatten(object, "", profileKey("", profile), profilePointer)
+	}
+}
+
+// Sets value for key.
+func (s *JSONSource) set(key, value, pointer string) {
+	s.values[key] = value
+	s.pointers[key] = pointer
+
+	delete(s.invalid, key)
+}
+
+// Returns string representation of JSON value.
+func jsonString(value interface{}) string {
+	switch value := value.(type) {
+	case string:
+		return value
+	case json.Number:
+		return value.String()
+	default:
+		data, _ := json.Marshal(value)
+
+		return string(data)
+	}
+}
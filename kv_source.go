@@ -0,0 +1,342 @@
+package sec
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default maximum time blocking query might wait for changes.
+const defaultKVWaitTime = time.Minute
+
+var (
+	errKVConfig  = errors.New("invalid key/value source configuration")
+	errKVRequest = errors.New("key/value storage request failed")
+)
+
+// KVOptions represents configuration for KVSource.
+type KVOptions struct {
+	// Address is storage's address, like "http://127.0.0.1:8500".
+	Address string
+	// Prefix is a prefix of keys, like "apps/billing/". Keys under
+	// prefix are listed recursively.
+	Prefix string
+	// Keys is a list of keys (relative to prefix) that should be read
+	// one by one as raw values instead of recursive listing. Useful
+	// when listing isn't permitted.
+	Keys []string
+	// Token is sent in X-Consul-Token header if not empty.
+	Token string
+	// KeyMapper converts storage's key (relative to prefix) into source
+	// key. By default slashes, dashes and dots are replaced with
+	// underscores and key is uppercased, so "database/max-conns" will
+	// provide "DATABASE_MAX_CONNS" key.
+	KeyMapper func(name string) string
+	// WaitTime is a maximum time blocking query used in Changed() waits
+	// for changes. Defaults to one minute.
+	WaitTime time.Duration
+	// HTTPClient is a client used for requests. Defaults to client with
+	// timeout which is 30 seconds longer than WaitTime.
+	HTTPClient *http.Client
+}
+
+// KVSource is a source which reads keys under prefix from Consul's (or
+// compatible) key/value HTTP API. Keys are read once per parsing.
+// Changed() might be used for detecting changes.
+type KVSource struct {
+	options KVOptions
+
+	mutex  sync.RWMutex
+	values map[string]string
+	names  map[string]string
+	// States of performed requests keyed by requests paths.
+	states map[string]kvState
+}
+
+// State of request used for detecting changes.
+type kvState struct {
+	// Value of X-Consul-Index header, used for blocking queries.
+	index string
+	// Value of ETag header, used for conditional requests.
+	etag string
+	// Hash of response body.
+	hash [sha256.Size]byte
+}
+
+// Value of key as returned by Consul when listing keys.
+type kvEntry struct {
+	Key   string
+	Value []byte
+}
+
+// NewKVSource creates new key/value storage source. Nothing is requested
+// until parsing.
+func NewKVSource(config *KVOptions) (*KVSource, error) {
+	options := KVOptions{}
+	if config != nil {
+		options = *config
+	}
+
+	if options.Address == "" {
+		return nil, fmt.Errorf("%w: address should be set", errKVConfig)
+	}
+
+	options.Address = strings.TrimRight(options.Address, "/")
+	options.Prefix = strings.TrimLeft(options.Prefix, "/")
+
+	if options.KeyMapper == nil {
+		options.KeyMapper = defaultKVKeyMapper
+	}
+
+	if options.WaitTime <= 0 {
+		options.WaitTime = defaultKVWaitTime
+	}
+
+	if options.HTTPClient == nil {
+		options.HTTPClient = &http.Client{Timeout: options.WaitTime + defaultHTTPTimeout}
+	}
+
+	return &KVSource{
+		options: options,
+		values:  make(map[string]string),
+		names:   make(map[string]string),
+		states:  make(map[string]kvState),
+	}, nil
+}
+
+// Load reads keys from storage.
+func (s *KVSource) Load(ctx context.Context) error {
+	values := make(map[string]string)
+	names := make(map[string]string)
+	states := make(map[string]kvState)
+
+	for _, path := range s.paths() {
+		body, state, found, err := s.request(ctx, path, kvState{}, false)
+		if err != nil {
+			return err
+		}
+
+		states[path] = state
+
+		if !found {
+			continue
+		}
+
+		entries, err := s.entries(path, body)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			// Folders are listed as keys with trailing slash.
+			if !strings.HasPrefix(entry.Key, s.options.Prefix) || strings.HasSuffix(entry.Key, "/") {
+				continue
+			}
+
+			key := s.options.KeyMapper(strings.TrimLeft(strings.TrimPrefix(entry.Key, s.options.Prefix), "/"))
+			if key == "" {
+				continue
+			}
+
+			values[key] = string(entry.Value)
+			names[key] = entry.Key
+		}
+	}
+
+	s.mutex.Lock()
+	s.values = values
+	s.names = names
+	s.states = states
+	s.mutex.Unlock()
+
+	printDebug("Read %d keys from '%s' with prefix '%s'", len(values), s.options.Address, s.options.Prefix)
+
+	return nil
+}
+
+// Changed checks if keys were changed since last Load(). If storage
+// supports blocking queries (returns X-Consul-Index header) and source
+// makes single request, then it waits for changes up to WaitTime or
+// until context is done. Otherwise conditional requests with ETag are
+// used. Values aren't updated, next parsing will read new ones.
+func (s *KVSource) Changed(ctx context.Context) (bool, error) {
+	s.mutex.RLock()
+	states := s.states
+	s.mutex.RUnlock()
+
+	paths := s.paths()
+	blocking := len(paths) == 1
+
+	for _, path := range paths {
+		state, loaded := states[path]
+		if !loaded {
+			return true, nil
+		}
+
+		_, newState, _, err := s.request(ctx, path, state, blocking)
+		if err != nil {
+			return false, err
+		}
+
+		if newState.hash != state.hash {
+			printDebug("Keys at '%s' were changed", path)
+
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Lookup returns value of key.
+func (s *KVSource) Lookup(key string) (string, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	value, found := s.values[key]
+
+	return value, found
+}
+
+// Keys returns read keys.
+func (s *KVSource) Keys() []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	keys := make([]string, 0, len(s.values))
+
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// Origin returns storage's address and key value was read from.
+func (s *KVSource) Origin(key string) (Origin, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	name, found := s.names[key]
+	if !found {
+		return Origin{}, false
+	}
+
+	return Origin{Source: "kv", Key: key, File: s.options.Address, Pointer: "/" + name}, true
+}
+
+// Returns paths of requests that should be performed for reading keys.
+func (s *KVSource) paths() []string {
+	if len(s.options.Keys) == 0 {
+		return []string{"/v1/kv/" + s.options.Prefix + "?recurse=true"}
+	}
+
+	paths := make([]string, 0, len(s.options.Keys))
+
+	for _, key := range s.options.Keys {
+		paths = append(paths, "/v1/kv/"+joinKVKey(s.options.Prefix, key)+"?raw=true")
+	}
+
+	return paths
+}
+
+// Converts response body into list of entries. Raw values are returned
+// as is, lists are JSON arrays with base64-encoded values.
+func (s *KVSource) entries(path string, body []byte) ([]kvEntry, error) {
+	if strings.HasSuffix(path, "?raw=true") {
+		key := strings.TrimSuffix(strings.TrimPrefix(path, "/v1/kv/"), "?raw=true")
+
+		return []kvEntry{{Key: key, Value: body}}, nil
+	}
+
+	var entries []kvEntry
+
+	err := json.Unmarshal(body, &entries)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid keys list: %s", errKVRequest, err.Error())
+	}
+
+	return entries, nil
+}
+
+// Performs request. If previous state is passed, then request will be
+// conditional (and blocking, if asked and supported). Second returned
+// value is a state of response, third indicates that keys were found.
+func (s *KVSource) request(
+	ctx context.Context, path string, state kvState, blocking bool,
+) ([]byte, kvState, bool, error) {
+	requestURL := s.options.Address + path
+
+	if blocking && state.index != "" {
+		requestURL += "&index=" + url.QueryEscape(state.index) + "&wait=" + url.QueryEscape(s.options.WaitTime.String())
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, state, false, newRequestError(errKVRequest, err)
+	}
+
+	if s.options.Token != "" {
+		request.Header.Set("X-Consul-Token", s.options.Token)
+	}
+
+	if state.etag != "" {
+		request.Header.Set("If-None-Match", state.etag)
+	}
+
+	response, err := s.options.HTTPClient.Do(request)
+	if err != nil {
+		return nil, state, false, newRequestError(errKVRequest, err)
+	}
+
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, defaultMaxFileSize))
+	if err != nil {
+		return nil, state, false, newRequestError(errKVRequest, err)
+	}
+
+	switch response.StatusCode {
+	case http.StatusNotModified:
+		return nil, state, true, nil
+	case http.StatusOK, http.StatusNotFound:
+	default:
+		return nil, state, false, fmt.Errorf("%w: GET %s: status %d: %s",
+			errKVRequest, path, response.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	found := response.StatusCode == http.StatusOK
+	if !found {
+		// Missing keys are treated as empty response, so their
+		// appearance will be noticed.
+		body = nil
+	}
+
+	return body, kvState{
+		index: response.Header.Get("X-Consul-Index"),
+		etag:  response.Header.Get("ETag"),
+		hash:  sha256.Sum256(body),
+	}, found, nil
+}
+
+// Converts storage's key into source key.
+func defaultKVKeyMapper(name string) string {
+	return defaultDirKeyMapper(strings.ReplaceAll(name, "/", "_"))
+}
+
+// Joins prefix and key.
+func joinKVKey(prefix, key string) string {
+	if prefix == "" || strings.HasSuffix(prefix, "/") {
+		return prefix + strings.TrimLeft(key, "/")
+	}
+
+	return prefix + "/" + strings.TrimLeft(key, "/")
+}
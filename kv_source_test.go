@@ -0,0 +1,244 @@
+// nolint:exhaustruct
+package sec
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Stand-in of Consul's key/value API.
+type testKV struct {
+	mutex sync.Mutex
+	index int
+	keys  map[string]string
+}
+
+// Sets key and increments index.
+func (kv *testKV) set(key, value string) {
+	kv.mutex.Lock()
+	defer kv.mutex.Unlock()
+
+	kv.keys[key] = value
+	kv.index++
+}
+
+// Returns current index and keys with passed prefix.
+func (kv *testKV) list(prefix string) (int, []kvEntry) {
+	kv.mutex.Lock()
+	defer kv.mutex.Unlock()
+
+	entries := make([]kvEntry, 0)
+
+	for key, value := range kv.keys {
+		if strings.HasPrefix(key, prefix) {
+			entries = append(entries, kvEntry{Key: key, Value: []byte(value)})
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+
+	return kv.index, entries
+}
+
+func newTestKV(t *testing.T) (*httptest.Server, *testKV) {
+	t.Helper()
+
+	kv := &testKV{index: 1, keys: map[string]string{
+		"apps/billing/":                 "",
+		"apps/billing/database/uri":     "postgres://localhost/billing",
+		"apps/billing/database/maxconn": "10",
+		"apps/billing/debug":            "true",
+		"apps/other/debug":              "false",
+	}}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Consul-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte("ACL not found"))
+
+			return
+		}
+
+		key := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+
+		// Blocking queries are supported only for listing, raw values
+		// support only ETags, like in generic key/value storages.
+		if index := r.URL.Query().Get("index"); index != "" && r.URL.Query().Get("raw") == "" {
+			wait, _ := time.ParseDuration(r.URL.Query().Get("wait"))
+			deadline := time.Now().Add(wait)
+
+			for time.Now().Before(deadline) && r.Context().Err() == nil {
+				current, _ := kv.list(key)
+				if strconv.Itoa(current) != index {
+					break
+				}
+
+				time.Sleep(10 * time.Millisecond)
+			}
+		}
+
+		index, entries := kv.list(key)
+		w.Header().Set("X-Consul-Index", strconv.Itoa(index))
+
+		if r.URL.Query().Get("raw") != "" {
+			for _, entry := range entries {
+				if entry.Key == key {
+					etag := `"` + string(entry.Value) + `"`
+					if r.Header.Get("If-None-Match") == etag {
+						w.WriteHeader(http.StatusNotModified)
+
+						return
+					}
+
+					w.Header().Set("ETag", etag)
+					_, _ = w.Write(entry.Value)
+
+					return
+				}
+			}
+
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+
+		if len(entries) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(entries)
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server, kv
+}
+
+func TestParseFromKVSource(t *testing.T) {
+	t.Parallel()
+
+	type testStruct struct {
+		Database struct {
+			URI     string
+			MaxConn int
+		}
+		Debug bool
+	}
+
+	server, kv := newTestKV(t)
+
+	for _, options := range []*KVOptions{
+		{Address: server.URL, Prefix: "apps/billing/", Token: "token"},
+		{
+			Address: server.URL, Prefix: "apps/billing", Token: "token",
+			Keys: []string{"database/uri", "database/maxconn", "debug", "missing"},
+		},
+	} {
+		source, err := NewKVSource(options)
+		require.Nil(t, err)
+
+		s := &testStruct{}
+
+		result, err := ParseWithResult(s, &Options{Source: source})
+		require.Nil(t, err)
+		require.Equal(t, "postgres://localhost/billing", s.Database.URI)
+		require.Equal(t, 10, s.Database.MaxConn)
+		require.True(t, s.Debug)
+
+		origin, found := result.Origin("Database.MaxConn")
+		require.True(t, found)
+		require.Equal(t, "kv "+server.URL+"#/apps/billing/database/maxconn (DATABASE_MAXCONN)", origin.String())
+	}
+
+	// Missing prefix isn't an error.
+	source, err := NewKVSource(&KVOptions{Address: server.URL, Prefix: "apps/missing/", Token: "token"})
+	require.Nil(t, err)
+	require.Nil(t, Parse(&testStruct{}, &Options{Source: source}))
+
+	// Changes detection with blocking queries and ETags.
+	blocking, err := NewKVSource(&KVOptions{
+		Address: server.URL, Prefix: "apps/billing/", Token: "token", WaitTime: 100 * time.Millisecond,
+	})
+	require.Nil(t, err)
+
+	conditional, err := NewKVSource(&KVOptions{
+		Address: server.URL, Prefix: "apps/billing/", Token: "token", Keys: []string{"debug"},
+	})
+	require.Nil(t, err)
+
+	for _, source := range []*KVSource{blocking, conditional} {
+		changed, err := source.Changed(context.Background())
+		require.Nil(t, err)
+		require.True(t, changed, "not loaded source should be reported as changed")
+
+		require.Nil(t, source.Load(context.Background()))
+
+		changed, err = source.Changed(context.Background())
+		require.Nil(t, err)
+		require.False(t, changed)
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		kv.set("apps/billing/debug", "false")
+	}()
+
+	changed, err := blocking.Changed(context.Background())
+	require.Nil(t, err)
+	require.True(t, changed)
+
+	changed, err = conditional.Changed(context.Background())
+	require.Nil(t, err)
+	require.True(t, changed)
+
+	// Values are updated only by next parsing.
+	value, _ := blocking.Lookup("DEBUG")
+	require.Equal(t, "true", value)
+
+	s := &testStruct{}
+	require.Nil(t, Parse(s, &Options{Source: blocking}))
+	require.False(t, s.Debug)
+}
+
+func TestKVSourceErrors(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newTestKV(t)
+
+	_, err := NewKVSource(&KVOptions{Prefix: "apps/billing/"})
+	require.True(t, errors.Is(err, errKVConfig))
+
+	source, err := NewKVSource(&KVOptions{Address: server.URL, Prefix: "apps/billing/", Token: "wrong"})
+	require.Nil(t, err)
+
+	err = Parse(&struct{ Debug bool }{}, &Options{Source: source})
+	require.True(t, errors.Is(err, errKVRequest))
+	require.Contains(t, err.Error(), "status 403: ACL not found")
+
+	// Context should be honoured by blocking queries.
+	source, err = NewKVSource(&KVOptions{
+		Address: server.URL, Prefix: "apps/billing/", Token: "token", WaitTime: time.Hour,
+	})
+	require.Nil(t, err)
+	require.Nil(t, source.Load(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = source.Changed(ctx)
+	require.True(t, errors.Is(err, errKVRequest))
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+}
@@ -0,0 +1,46 @@
+package sec
+
+import (
+	"context"
+)
+
+// Loader is implemented by sources which fetch values from remote
+// storages, like Vault. Load is called once at the beginning of every
+// parsing, so values are fetched once and stay same while parsing.
+type Loader interface {
+	// Load fetches values from storage.
+	Load(ctx context.Context) error
+}
+
+// Load loads every source that implements Loader interface.
+func (s Layers) Load(ctx context.Context) error {
+	for _, source := range s {
+		err := loadSource(ctx, source)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Load loads wrapped source.
+func (s *namedSource) Load(ctx context.Context) error {
+	return loadSource(ctx, s.Source)
+}
+
+// Load loads fallback source.
+func (s *CredentialsSource) Load(ctx context.Context) error {
+	return loadSource(ctx, s.fallback)
+}
+
+// Loads source if it implements Loader interface.
+func loadSource(ctx context.Context, source Source) error {
+	if loader, ok := source.(Loader); ok {
+		printDebug("Loading source %T", source)
+
+		return loader.Load(ctx)
+	}
+
+	return nil
+}
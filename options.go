@@ -9,8 +9,76 @@ type Options struct {
 	// SEC_DEBUG environment variable and passing not a pointer to
 	// structure to Parse() function.
 	ErrorsAreCritical bool
+	// AllowSharedEnvNames allows several fields to be mapped to same
+	// environment variable. By default such collisions (e.g. embedded
+	// structure's field with same name as parent's field) will produce
+	// an error listing every conflicting field.
+	AllowSharedEnvNames bool
+	// MaxDepth is a maximum structures nesting level. Structures nested
+	// deeper will be ignored and nil pointers to them won't be
+	// initialized. Zero means no limit, but in this case recursive
+	// types (like linked list nodes) will produce an error.
+	MaxDepth int
+	// ReplaceInterfaceValues allows parsing values that were put into
+	// interface{} not as pointers. Such values aren't addressable, so
+	// they will be copied, parsed and then stored back into interface.
+	// Typed nil pointers put into interface{} are allocated too, they
+	// are ignored otherwise.
+	ReplaceInterfaceValues bool
+	// AllowUnexported allows setting unexported fields, including
+	// unexported embedded pointers to structures. As 'reflect' package
+	// doesn't allow that, such fields will be set using 'unsafe' package.
+	AllowUnexported bool
+	// Source is a storage values will be taken from. By default
+	// operating system's environment is used.
+	Source Source
+	// FileVariables allows reading values from files. If "<NAME>" isn't
+	// set, but "<NAME>_FILE" is - value will be read from file it
+	// points to (with trailing new lines trimmed). Can be enabled for
+	// separate fields with `sec:"file"` tag.
+	FileVariables bool
+	// ExpandVariables enables expanding of ${VAR}, ${VAR:-default} and
+	// ${VAR:?message} references in values. Referenced variables are
+	// taken from same source. Use "$$" for literal dollar sign or
+	// `sec:"noexpand"` tag to disable expanding for field.
+	ExpandVariables bool
+	// ProfileVariable is a name of variable with active profile name,
+	// like "APP_ENV". If profile is active, then profile specific values
+	// (like "DATABASE_URI__PRODUCTION" variable or `default.production`
+	// tag) will be used before regular ones. Empty string disables
+	// profiles.
+	ProfileVariable string
+	// SecretPatterns is a list of substrings of variables names which
+	// indicate that field contains secret (in addition to fields with
+	// `sec:"secret"` tag). Values of such fields are masked in debug
+	// output and errors. If nil, then default patterns are used:
+	// PASSWORD, PASSWD, SECRET, TOKEN, KEY, CREDENTIAL and PRIVATE.
+	// Pass empty slice to disable detection by names.
+	SecretPatterns []string
+	// UnsetSecrets removes environment variables values of secret
+	// fields were taken from after successful parsing, so they won't
+	// be inherited by child processes. Single fields can be marked
+	// with `sec:"unset"` tag instead.
+	UnsetSecrets bool
+	// DecryptionKeyVariable is a name of variable with base64-encoded
+	// AES key for decrypting values with "enc:v1:" prefix. Key is
+	// looked up in Source, if variable isn't set, then it will be read
+	// from file pointed by variable with "_FILE" suffix. Decrypted
+	// fields are treated as secrets.
+	DecryptionKeyVariable string
 }
 
 var defaultOptions = &Options{
-	ErrorsAreCritical: false,
+	ErrorsAreCritical:      false,
+	AllowSharedEnvNames:    false,
+	MaxDepth:               0,
+	ReplaceInterfaceValues: false,
+	AllowUnexported:        false,
+	Source:                 nil,
+	FileVariables:          false,
+	ExpandVariables:        false,
+	ProfileVariable:        "",
+	SecretPatterns:         nil,
+	UnsetSecrets:           false,
+	DecryptionKeyVariable:  "",
 }
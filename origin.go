@@ -0,0 +1,148 @@
+package sec
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Origin describes where field's value came from.
+type Origin struct {
+	// Source is a name of source, like "env" or "dotenv".
+	Source string
+	// Key is a name of key (environment variable) value was taken from.
+	Key string
+	// File is a path to file value was defined in, if source is file
+	// based.
+	File string
+	// Line is a line number in file, starting from 1. Zero if unknown.
+	Line int
+	// Pointer is a JSON pointer to value (like "/database/uri") if
+	// value came from JSON file.
+	Pointer string
+
+	// Value was taken from process environment. Source might be
+	// renamed, so it can't be used for that.
+	environment bool
+}
+
+// String returns human-readable origin representation, like
+// "dotenv .env:3 (DATABASE_URI)" or "json config.json#/database/uri
+// (DATABASE_URI)".
+func (o Origin) String() string {
+	if o.File == "" {
+		return fmt.Sprintf("%s (%s)", o.Source, o.Key)
+	}
+
+	file := o.File
+	if o.Line > 0 {
+		file += ":" + strconv.Itoa(o.Line)
+	}
+
+	if o.Pointer != "" {
+		file += "#" + o.Pointer
+	}
+
+	return fmt.Sprintf("%s %s (%s)", o.Source, file, o.Key)
+}
+
+// Returns true if value is a whole file's contents, like value of file
+// from "_FILE" variable, systemd credential or file from directory.
+// Such values are usually secrets.
+func (o Origin) wholeFile() bool {
+	return o.File != "" && o.Line == 0 && o.Pointer == ""
+}
+
+// OriginSource is implemented by sources that can tell where their
+// values came from.
+type OriginSource interface {
+	// Origin returns origin of value for passed key. Second returned
+	// value indicates that key was found.
+	Origin(key string) (Origin, bool)
+}
+
+// Named gives name to source, which will be reported as Origin.Source
+// for values taken from it. Useful for distinguishing several sources
+// of same type, e.g. committed config file and local overrides file.
+func Named(name string, source Source) Source {
+	return &namedSource{Source: source, name: name}
+}
+
+// Source with custom name.
+type namedSource struct {
+	Source
+	name string
+}
+
+// Origin returns origin of value with source name replaced.
+func (s *namedSource) Origin(key string) (Origin, bool) {
+	origin, found := originOf(s.Source, key)
+	if !found {
+		return origin, false
+	}
+
+	origin.Source = s.name
+
+	return origin, true
+}
+
+// Origin returns origin of environment variable.
+func (s EnvSource) Origin(key string) (Origin, bool) {
+	if _, found := s.Lookup(key); !found {
+		return Origin{}, false
+	}
+
+	return Origin{Source: "env", Key: key, environment: true}, true
+}
+
+// Origin returns origin of value from map.
+func (s MapSource) Origin(key string) (Origin, bool) {
+	if _, found := s.Lookup(key); !found {
+		return Origin{}, false
+	}
+
+	return Origin{Source: "map", Key: key}, true
+}
+
+// Origin returns file and line value was defined at.
+func (s *DotenvSource) Origin(key string) (Origin, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	loc, found := s.locations[key]
+	if !found {
+		return Origin{}, false
+	}
+
+	return Origin{Source: "dotenv", Key: key, File: loc.File, Line: loc.Line}, true
+}
+
+// Origin returns origin of value from source with highest priority
+// that has it.
+func (s Layers) Origin(key string) (Origin, bool) {
+	for idx := len(s) - 1; idx >= 0; idx-- {
+		if origin, found := originOf(s[idx], key); found {
+			return origin, true
+		}
+	}
+
+	return Origin{}, false
+}
+
+// Returns origin of value for passed key. If source doesn't implement
+// OriginSource - it's type will be used as source name.
+func originOf(source Source, key string) (Origin, bool) {
+	if originSource, ok := source.(OriginSource); ok {
+		return originSource.Origin(key)
+	}
+
+	if _, found := source.Lookup(key); !found {
+		return Origin{}, false
+	}
+
+	return Origin{Source: sourceName(source), Key: key}, true
+}
+
+// Returns name of source which doesn't implement OriginSource.
+func sourceName(source Source) string {
+	return fmt.Sprintf("%T", source)
+}
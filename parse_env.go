@@ -2,10 +2,15 @@ package sec
 
 import (
 	"errors"
-	"os"
+	"fmt"
 )
 
+// Suffix of variable which contains path to file with field's value.
+const fileVariableSuffix = "_FILE"
+
 var (
+	errValueAndFile = errors.New("both value and file variables are set")
+
 	errNotBool = errors.New("environment variable doesn't contain boolean")
 
 	errNotFloat   = errors.New("environment variable doesn't contain floating point number")
@@ -25,27 +30,134 @@ var (
 	errNotUint64 = errors.New("environment variable doesn't contain uint64")
 )
 
-// Parses environment for data.
-func parseEnv() error {
-	printDebug("Starting parsing data into tree from environment variables...")
+// Parses source for data.
+func (p *parser) parseEnv() error {
+	printDebug("Starting parsing data into tree from source...")
 
-	for _, element := range parsedTree {
+	for _, element := range p.tree {
 		printDebug("Processing element '%s'", element.EnvVar)
 
-		data, found := os.LookupEnv(element.EnvVar)
+		err := p.canceled(element)
+		if err != nil {
+			return err
+		}
+
+		data, origin, found, err := p.lookup(element)
+		if err != nil {
+			// Sources report context errors in different ways, so
+			// cancellation is checked explicitly.
+			if canceledErr := p.canceled(element); canceledErr != nil {
+				return canceledErr
+			}
+
+			return err
+		}
+
 		if !found {
 			printDebug("Value for '%s' environment variable wasn't found", element.EnvVar)
 
 			continue
-		} else {
-			printDebug("Value for '%s' will be: %s", element.EnvVar, data)
 		}
 
-		err := fillValue(element, data)
+		printDebug("Value for '%s' came from %s", element.EnvVar, origin.String())
+
+		p.origins[element.Path] = origin
+
+		data, err = p.prepareValue(element, data, origin)
+		if err == nil {
+			err = p.fillValue(element, data)
+		}
+
 		if err != nil {
+			if canceledErr := p.canceled(element); canceledErr != nil {
+				return canceledErr
+			}
+
+			// Values from files should be easy to find.
+			if origin.File != "" {
+				return fmt.Errorf("%w: value from %s", err, origin.String())
+			}
+
 			return err
 		}
 	}
 
 	return nil
 }
+
+// Looks up raw value for field. Values are looked up in this order:
+// profile specific variable, variable itself, file from "_FILE"
+// variable, profile specific default and default from field's tag.
+// Returns value, it's origin and flag indicating that value was found.
+func (p *parser) lookup(element *field) (string, Origin, bool, error) {
+	data, origin, found, err := p.lookupProfile(element)
+	if err != nil {
+		return "", Origin{}, false, err
+	}
+
+	if !found {
+		data, origin, found, err = lookupField(p.ctx, p.source, element.info())
+		if err != nil {
+			return "", Origin{}, false, err
+		}
+	}
+
+	if p.options.FileVariables || element.Tag.Has("file") {
+		fileVar := element.EnvVar + fileVariableSuffix
+
+		path, fileFound, err := p.lookupKey(fileVar)
+		if err != nil {
+			return "", Origin{}, false, err
+		}
+
+		if fileFound {
+			if found {
+				return "", Origin{}, false, fmt.Errorf("%w: '%s' and '%s'", errValueAndFile, element.EnvVar, fileVar)
+			}
+
+			fileData, err := readValueFile(path, defaultMaxFileSize)
+			if err != nil {
+				return "", Origin{}, false, fmt.Errorf("%w (path from '%s')", err, fileVar)
+			}
+
+			// File contents are usually secrets, so they should not be
+			// printed.
+			printDebug("Value for '%s' was read from file '%s'", element.EnvVar, path)
+
+			return fileData, Origin{Source: "file", Key: fileVar, File: path}, true, nil
+		}
+	}
+
+	if !found {
+		data, origin, found = p.lookupDefault(element)
+		if !found {
+			return "", Origin{}, false, nil
+		}
+	}
+
+	return data, origin, true, nil
+}
+
+// Decrypts value if it is encrypted and then expands references in it,
+// so references in ciphertext aren't touched and references in
+// decrypted value are expanded.
+func (p *parser) prepareValue(element *field, data string, origin Origin) (string, error) {
+	data, err := p.decryptValue(element, data)
+	if err != nil {
+		return "", err
+	}
+
+	if p.options.ExpandVariables && !element.Tag.Has("noexpand") {
+		data, err = p.expand(element, data, []string{element.EnvVar})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// Files contents are usually secrets, so they should not be printed.
+	if !origin.wholeFile() {
+		printDebug("Value for '%s' will be: %s", element.EnvVar, element.display(data))
+	}
+
+	return data, nil
+}
@@ -0,0 +1,99 @@
+package sec
+
+import (
+	"context"
+	"crypto/cipher"
+	"fmt"
+)
+
+// This structure holds state of single Parse() run, so several
+// structures can be parsed simultaneously.
+type parser struct {
+	// Context of current run.
+	ctx context.Context
+	// Options for current run.
+	options *Options
+	// Source values will be taken from.
+	source Source
+	// Parsed structure fields.
+	tree []*field
+	// Values copied from interfaces that should be stored back.
+	interfaceValues []*interfaceValue
+	// Origins of values keyed by fields paths.
+	origins map[string]Origin
+	// Fields paths of interfaces keyed by discriminators (like
+	// "STORAGE_TYPE") used for choosing their implementations.
+	discriminators map[string]string
+	// Active profile name.
+	profile string
+	// Environment variables that were unset after parsing.
+	unset []string
+	// Values of environment variables that were unset after parsing,
+	// keyed by variables names.
+	consumed map[string]string
+	// Path of package passed structure was declared in. Unexported
+	// fields are set only in types from this package.
+	packagePath string
+	// Cipher for decrypting values, created on first use.
+	aead cipher.AEAD
+}
+
+// Creates new parser with passed options. If options are nil - default
+// ones will be used.
+func newParser(ctx context.Context, config *Options) *parser {
+	options := config
+	if options == nil {
+		options = defaultOptions
+	}
+
+	var source Source = EnvSource{}
+	if options.Source != nil {
+		source = options.Source
+	}
+
+	return &parser{
+		ctx:             ctx,
+		options:         options,
+		source:          source,
+		tree:            []*field{},
+		interfaceValues: []*interfaceValue{},
+		origins:         make(map[string]Origin),
+		discriminators:  make(map[string]string),
+		unset:           []string{},
+		consumed:        make(map[string]string),
+	}
+}
+
+// Looks up value for key which doesn't belong to field (like profile
+// variable or "_FILE" variable), passing parser's context to source.
+func (p *parser) lookupKey(key string) (string, bool, error) {
+	value, _, found, err := lookupField(p.ctx, p.source, FieldInfo{Key: key})
+	if err != nil {
+		// Sources report context errors in different ways, so
+		// cancellation is checked explicitly.
+		if ctxErr := p.ctx.Err(); ctxErr != nil {
+			return "", false, &CanceledError{Key: key, Err: ctxErr}
+		}
+
+		return "", false, err
+	}
+
+	return value, found, nil
+}
+
+// Returns information about finished parsing.
+func (p *parser) result() *Result {
+	return &Result{origins: p.origins, profile: p.profile, values: p.values(), unset: p.unset}
+}
+
+// Returns current values of parsed fields keyed by fields paths. Values
+// of secret fields are masked.
+func (p *parser) values() map[string]string {
+	values := make(map[string]string, len(p.tree))
+
+	for _, element := range p.tree {
+		values[element.Path] = element.display(fmt.Sprint(element.Pointer.Interface()))
+	}
+
+	return values
+}
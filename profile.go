@@ -0,0 +1,75 @@
+package sec
+
+import (
+	"strings"
+)
+
+const (
+	// Separator between variable name and profile name in profile
+	// specific variables, like "DATABASE_URI__PRODUCTION".
+	profileSeparator = "__"
+	// Name of tag with default value.
+	defaultTagName = "default"
+	// Name of section (or object) in files which contains profiles
+	// specific values.
+	profilesSection = "PROFILES"
+)
+
+// Reads active profile name from source.
+func (p *parser) readProfile() error {
+	if p.options.ProfileVariable == "" {
+		return nil
+	}
+
+	profile, found, err := p.lookupKey(p.options.ProfileVariable)
+	if err != nil {
+		return err
+	}
+
+	if !found || profile == "" {
+		printDebug("Profile variable '%s' isn't set, no profile is active", p.options.ProfileVariable)
+
+		return nil
+	}
+
+	p.profile = profile
+
+	printDebug("Active profile: %s", p.profile)
+
+	return nil
+}
+
+// Looks up profile specific value for field, like "DATABASE_URI__PRODUCTION".
+func (p *parser) lookupProfile(element *field) (string, Origin, bool, error) {
+	if p.profile == "" {
+		return "", Origin{}, false, nil
+	}
+
+	info := element.info()
+	info.Key = profileKey(element.EnvVar, p.profile)
+	info.Profile = p.profile
+
+	return lookupField(p.ctx, p.source, info)
+}
+
+// Returns default value for field from `default.<profile>:"value"` or
+// `default:"value"` tags.
+func (p *parser) lookupDefault(element *field) (string, Origin, bool) {
+	tagNames := []string{defaultTagName}
+	if p.profile != "" {
+		tagNames = []string{defaultTagName + "." + strings.ToLower(p.profile), defaultTagName}
+	}
+
+	for _, tagName := range tagNames {
+		if value, found := element.StructTag.Lookup(tagName); found {
+			return value, Origin{Source: tagName, Key: element.EnvVar}, true
+		}
+	}
+
+	return "", Origin{}, false
+}
+
+// Composes profile specific key.
+func profileKey(key, profile string) string {
+	return key + profileSeparator + strings.ToUpper(profile)
+}
@@ -0,0 +1,29 @@
+package sec
+
+// Error of request to remote storage. It matches sentinel error passed
+// as kind with errors.Is() and keeps underlying error (like
+// context.DeadlineExceeded) in chain.
+type requestError struct {
+	kind error
+	err  error
+}
+
+// Wraps request's error into sentinel error.
+func newRequestError(kind, err error) error {
+	return &requestError{kind: kind, err: err}
+}
+
+// Error returns sentinel error's text followed by request's error text.
+func (e *requestError) Error() string {
+	return e.kind.Error() + ": " + e.err.Error()
+}
+
+// Is reports whether target is a sentinel error of this error.
+func (e *requestError) Is(target error) bool {
+	return target == e.kind
+}
+
+// Unwrap returns request's error.
+func (e *requestError) Unwrap() error {
+	return e.err
+}
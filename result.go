@@ -0,0 +1,63 @@
+package sec
+
+// Result contains information about finished parsing.
+type Result struct {
+	// Origins of values keyed by fields paths.
+	origins map[string]Origin
+	// Active profile name.
+	profile string
+	// Values of fields after parsing keyed by fields paths.
+	values map[string]string
+	// Environment variables that were unset after parsing.
+	unset []string
+}
+
+// Profile returns name of profile that was active while parsing or
+// empty string if no profile was active.
+func (r *Result) Profile() string {
+	return r.profile
+}
+
+// Origin returns origin of value for field with passed path, like
+// "Database.URI". Second returned value is false if value for field
+// wasn't found in sources.
+func (r *Result) Origin(path string) (Origin, bool) {
+	origin, found := r.origins[path]
+
+	return origin, found
+}
+
+// Origins returns origins of values for all fields that got them from
+// sources, keyed by fields paths.
+func (r *Result) Origins() map[string]Origin {
+	origins := make(map[string]Origin, len(r.origins))
+
+	for path, origin := range r.origins {
+		origins[path] = origin
+	}
+
+	return origins
+}
+
+// Values returns values of all parsed fields after parsing, keyed by
+// fields paths. Values of secret fields are masked, so it is safe to
+// print them.
+func (r *Result) Values() map[string]string {
+	values := make(map[string]string, len(r.values))
+
+	for path, value := range r.values {
+		values[path] = value
+	}
+
+	return values
+}
+
+// Unset returns sorted names of environment variables that were removed
+// from process environment after parsing because of UnsetSecrets option
+// or `sec:"unset"` tag.
+func (r *Result) Unset() []string {
+	unset := make([]string, len(r.unset))
+	copy(unset, r.unset)
+
+	return unset
+}
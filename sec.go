@@ -1,11 +1,13 @@
 package sec
 
 import (
+	"context"
 	"errors"
 	"log"
 	"os"
 	"reflect"
 	"strconv"
+	"sync/atomic"
 )
 
 var (
@@ -13,36 +15,54 @@ var (
 	errNotPTR       = errors.New("passed data is not a pointer")
 	errNotStructure = errors.New("passed data is not a structure")
 
-	// Debug flag.
+	// Debug flag. Parsing might be done in background goroutines (e.g.
+	// by Watch()), so flag is accessed atomically.
 	debugFlagEnvName = "SEC_DEBUG"
-	debug            bool
-
-	// Parsed structure fields.
-	parsedTree []*field
-
-	// Options for current run.
-	options *Options
+	debug            int32
 )
 
 // Parse parses environment variables into passed structure.
 func Parse(structure interface{}, config *Options) error {
-	parsedTree = []*field{}
+	return ParseContext(context.Background(), structure, config)
+}
 
-	options = config
-	if config == nil {
-		options = defaultOptions
-	}
+// ParseContext parses environment variables into passed structure. Context
+// is passed to sources and validator, parsing will be aborted with
+// *CanceledError when context is done.
+func ParseContext(ctx context.Context, structure interface{}, config *Options) error {
+	_, err := ParseWithResultContext(ctx, structure, config)
+
+	return err
+}
+
+// ParseWithResult parses environment variables into passed structure
+// and returns information about parsing, like values origins.
+func ParseWithResult(structure interface{}, config *Options) (*Result, error) {
+	return ParseWithResultContext(context.Background(), structure, config)
+}
+
+// ParseWithResultContext is a ParseWithResult() which passes context to
+// sources and validator, like ParseContext().
+func ParseWithResultContext(ctx context.Context, structure interface{}, config *Options) (*Result, error) {
+	p := newParser(ctx, config)
 
+	err := p.parse(structure)
+
+	return p.result(), err
+}
+
+// Parses passed structure.
+func (p *parser) parse(structure interface{}) error {
 	// Set debug flag if defined in environment.
 	debugFlagRaw, found := os.LookupEnv(debugFlagEnvName)
 	if found {
-		var err error
+		enabled, err := strconv.ParseBool(debugFlagRaw)
+		setDebug(enabled)
 
-		debug, err = strconv.ParseBool(debugFlagRaw)
 		if err != nil {
 			log.Printf("Invalid '%s' environment variable data: '%s'. Error: %s", debugFlagEnvName, debugFlagRaw, err.Error())
 
-			if options.ErrorsAreCritical {
+			if p.options.ErrorsAreCritical {
 				// nolint
 				return err
 			}
@@ -51,7 +71,11 @@ func Parse(structure interface{}, config *Options) error {
 		}
 	}
 
-	printDebug("Parsing started with configuration: %+v", options)
+	// Sources might contain secrets, so only source type is printed.
+	optionsToPrint := *p.options
+	optionsToPrint.Source = nil
+
+	printDebug("Parsing started with configuration: %+v, source: %T", optionsToPrint, p.source)
 
 	value := reflect.ValueOf(structure)
 
@@ -75,18 +99,57 @@ func Parse(structure interface{}, config *Options) error {
 		return errNotStructure
 	}
 
+	err := loadSource(p.ctx, p.source)
+	if err != nil {
+		// Sources report context errors in different ways, so
+		// cancellation is checked explicitly.
+		if ctxErr := p.ctx.Err(); ctxErr != nil {
+			return &CanceledError{Err: ctxErr}
+		}
+
+		return err
+	}
+
+	err = p.readProfile()
+	if err != nil {
+		return err
+	}
+
+	p.packagePath = structPackage(value.Type())
+
 	// Parse structure.
 	// As this is a very first function launch we should not use any
 	// prefixes.
-	composeTree(value, "")
+	err = p.composeTree(value, "", "", nil)
+	if err != nil {
+		return err
+	}
+
+	err = p.checkCollisions()
+	if err != nil {
+		return err
+	}
+
+	err = p.parseEnv()
+
+	p.storeInterfaceValues()
+
+	if err != nil {
+		return err
+	}
 
-	return parseEnv()
+	err = p.validate(structure)
+	if err != nil {
+		return err
+	}
+
+	return p.unsetConsumed()
 }
 
 // Produces debug output into stdout using standard log module if debug
 // mode was activated by setting SEC_DEBUG environment variable to true.
 func printDebug(text string, params ...interface{}) {
-	if debug {
+	if debugEnabled() {
 		if len(params) == 0 {
 			log.Println(text)
 		} else {
@@ -94,3 +157,18 @@ func printDebug(text string, params ...interface{}) {
 		}
 	}
 }
+
+// Sets debug flag.
+func setDebug(enabled bool) {
+	var value int32
+	if enabled {
+		value = 1
+	}
+
+	atomic.StoreInt32(&debug, value)
+}
+
+// Returns true if debug mode was activated.
+func debugEnabled() bool {
+	return atomic.LoadInt32(&debug) == 1
+}
@@ -2,9 +2,16 @@
 package sec
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"os"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -196,7 +203,7 @@ func TestInvalidDebugFlagValue(t *testing.T) {
 	err := Parse(c, nil)
 
 	require.Nil(t, err)
-	require.False(t, debug)
+	require.False(t, debugEnabled())
 
 	os.Unsetenv(debugFlagEnvName)
 }
@@ -212,7 +219,463 @@ func TestInvalidDebugFlagValueWithErrorsAreCritical(t *testing.T) {
 	}
 
 	require.NotNil(t, err)
-	require.False(t, debug)
+	require.False(t, debugEnabled())
 
 	os.Unsetenv(debugFlagEnvName)
 }
+
+func TestParseEnvNameCollisions(t *testing.T) {
+	type embedded struct {
+		Timeout int
+	}
+
+	type testStruct struct {
+		embedded
+		DB struct {
+			URI string
+		}
+		DB_URI  string // nolint:revive,stylecheck
+		Timeout int
+	}
+
+	t.Setenv("TIMEOUT", "10")
+	t.Setenv("DB_URI", "test")
+
+	c := &testStruct{}
+	err := Parse(c, nil)
+
+	require.NotNil(t, err)
+	require.True(t, errors.Is(err, errEnvNameCollision))
+	require.Contains(t, err.Error(), "'DB_URI' is used by DB.URI, DB_URI")
+	require.Contains(t, err.Error(), "'TIMEOUT' is used by embedded.Timeout, Timeout")
+
+	c1 := &testStruct{}
+	err1 := Parse(c1, &Options{AllowSharedEnvNames: true})
+
+	require.Nil(t, err1)
+	require.Equal(t, 10, c1.Timeout)
+	require.Equal(t, 10, c1.embedded.Timeout)
+	require.Equal(t, "test", c1.DB.URI)
+	require.Equal(t, "test", c1.DB_URI)
+}
+
+func TestParseRecursiveTypes(t *testing.T) {
+	type node struct {
+		Next  *node
+		Value string
+	}
+
+	type testStruct struct {
+		Head node
+	}
+
+	t.Setenv("HEAD_VALUE", "first")
+	t.Setenv("HEAD_NEXT_VALUE", "second")
+	t.Setenv("HEAD_NEXT_NEXT_VALUE", "third")
+
+	c := &testStruct{}
+	err := Parse(c, nil)
+
+	require.NotNil(t, err)
+	require.True(t, errors.Is(err, errRecursiveType))
+	require.Contains(t, err.Error(), "Head.Next")
+
+	c1 := &testStruct{}
+	err1 := Parse(c1, &Options{MaxDepth: 3})
+
+	require.Nil(t, err1)
+	require.Equal(t, "first", c1.Head.Value)
+	require.NotNil(t, c1.Head.Next)
+	require.Equal(t, "second", c1.Head.Next.Value)
+	require.Nil(t, c1.Head.Next.Next)
+}
+
+type testStorage interface {
+	Kind() string
+}
+
+type testS3Storage struct {
+	Bucket string
+}
+
+func (s *testS3Storage) Kind() string { return "s3" }
+
+type testFSStorage struct {
+	Path string
+}
+
+func (s *testFSStorage) Kind() string { return "fs" }
+
+type testTypedStorage struct {
+	Type string
+}
+
+func (s *testTypedStorage) Kind() string { return s.Type }
+
+func TestParseInterfaceImplementationFromFactory(t *testing.T) {
+	type testStruct struct {
+		Storage testStorage
+	}
+
+	t.Cleanup(func() {
+		unregisterFactories((*testStorage)(nil))
+	})
+
+	require.Nil(t, RegisterFactory((*testStorage)(nil), "s3", func() interface{} { return &testS3Storage{} }))
+	require.Nil(t, RegisterFactory((*testStorage)(nil), "fs", func() interface{} { return &testFSStorage{} }))
+	require.True(t, errors.Is(
+		RegisterFactory((*testStorage)(nil), "fs", func() interface{} { return &testFSStorage{} }),
+		errFactoryExists,
+	))
+	require.Equal(t, errNotInterfacePointer, RegisterFactory(&testS3Storage{}, "s3", nil))
+
+	t.Setenv("STORAGE_TYPE", "s3")
+	t.Setenv("STORAGE_BUCKET", "configs")
+	t.Setenv("STORAGE_PATH", "/var/lib/configs")
+
+	c := &testStruct{}
+	err := Parse(c, nil)
+
+	require.Nil(t, err)
+	require.Equal(t, &testS3Storage{Bucket: "configs"}, c.Storage)
+
+	t.Setenv("STORAGE_TYPE", "fs")
+
+	c1 := &testStruct{}
+	err1 := Parse(c1, nil)
+
+	require.Nil(t, err1)
+	require.Equal(t, &testFSStorage{Path: "/var/lib/configs"}, c1.Storage)
+
+	t.Setenv("STORAGE_TYPE", "memory")
+
+	c2 := &testStruct{}
+	err2 := Parse(c2, nil)
+
+	require.NotNil(t, err2)
+	require.True(t, errors.Is(err2, errUnknownImplementation))
+	require.Contains(t, err2.Error(), "known: fs, s3")
+
+	// Discriminator is reserved. Factories might register other
+	// factories.
+	require.Nil(t, RegisterFactory((*testStorage)(nil), "typed", func() interface{} {
+		_ = RegisterFactory((*testStorage)(nil), "other", func() interface{} { return &testFSStorage{} })
+
+		return &testTypedStorage{}
+	}))
+
+	t.Setenv("STORAGE_TYPE", "typed")
+
+	err3 := Parse(&testStruct{}, &Options{AllowSharedEnvNames: true})
+
+	require.True(t, errors.Is(err3, errEnvNameCollision))
+	require.Contains(t, err3.Error(), "'STORAGE_TYPE' is used by Storage.Type and chooses implementation for Storage")
+}
+
+func TestParseUnexportedFields(t *testing.T) {
+	type embedded struct {
+		Timeout int
+	}
+
+	type testStruct struct {
+		*embedded
+		database struct {
+			uri string
+		}
+		name  string
+		Start time.Time
+	}
+
+	t.Setenv("TIMEOUT", "10")
+	t.Setenv("START_WALL", "1")
+	t.Setenv("START_EXT", "1")
+	t.Setenv("DATABASE_URI", "postgres://localhost/app")
+	t.Setenv("NAME", "app")
+
+	c := &testStruct{}
+	err := Parse(c, nil)
+
+	require.Nil(t, err)
+	require.Nil(t, c.embedded)
+	require.Equal(t, "", c.database.uri)
+	require.Equal(t, "", c.name)
+
+	c1 := &testStruct{}
+	err1 := Parse(c1, &Options{AllowUnexported: true})
+
+	require.Nil(t, err1)
+	require.NotNil(t, c1.embedded)
+	require.Equal(t, 10, c1.embedded.Timeout)
+	require.Equal(t, "postgres://localhost/app", c1.database.uri)
+	require.Equal(t, "app", c1.name)
+
+	// Unexported fields of types from other packages shouldn't be set.
+	require.True(t, c1.Start.IsZero())
+	require.Equal(t, "UTC", c1.Start.Location().String())
+}
+
+func TestParseSecretsRedaction(t *testing.T) {
+	type testStruct struct {
+		Database struct {
+			URI      string `sec:"secret"`
+			Password string
+			Port     int
+		}
+		APIToken int
+		Host     string
+	}
+
+	var output bytes.Buffer
+
+	log.SetOutput(&output)
+	defer log.SetOutput(os.Stderr)
+
+	t.Setenv(debugFlagEnvName, "true")
+	defer setDebug(false)
+
+	source := MapSource{
+		"DATABASE_URI":      "postgres://user:uri-secret@db/app",
+		"DATABASE_PASSWORD": "password-secret",
+		"DATABASE_PORT":     "5432",
+		"APITOKEN":          "token-secret",
+		"HOST":              "localhost",
+	}
+
+	c := &testStruct{}
+	result, err := ParseWithResult(c, &Options{Source: source})
+
+	require.Nil(t, err)
+	require.Equal(t, "password-secret", c.Database.Password)
+	require.Equal(t, map[string]string{
+		"Database.URI":      maskedValue,
+		"Database.Password": maskedValue,
+		"Database.Port":     "5432",
+		"APIToken":          maskedValue,
+		"Host":              "localhost",
+	}, result.Values())
+
+	require.Contains(t, output.String(), "Value for 'HOST' will be: localhost")
+	require.Contains(t, output.String(), "Value for 'DATABASE_PASSWORD' will be: "+maskedValue)
+	require.Contains(t, output.String(), "parsing "+maskedValue+": invalid syntax")
+	require.NotContains(t, output.String(), "uri-secret")
+	require.NotContains(t, output.String(), "password-secret")
+	require.NotContains(t, output.String(), "token-secret")
+
+	// Detection by names can be disabled.
+	result, err = ParseWithResult(&testStruct{}, &Options{Source: source, SecretPatterns: []string{}})
+
+	require.Nil(t, err)
+	require.Equal(t, maskedValue, result.Values()["Database.URI"])
+	require.Equal(t, "password-secret", result.Values()["Database.Password"])
+
+	// Items of secret slices should be masked too.
+	type testSliceStruct struct {
+		Tokens []int `sec:"secret"`
+	}
+
+	err = Parse(&testSliceStruct{}, &Options{
+		Source:            MapSource{"TOKENS": "1,item-secret,3"},
+		ErrorsAreCritical: true,
+	})
+
+	require.NotNil(t, err)
+	require.NotContains(t, err.Error(), "item-secret")
+	require.NotContains(t, output.String(), "item-secret")
+}
+
+func TestParseSecretType(t *testing.T) {
+	t.Parallel()
+
+	type testStruct struct {
+		Password  Secret
+		Token     *Secret
+		Keys      []Secret
+		User      string
+		unexposed Secret
+	}
+
+	c := &testStruct{}
+	result, err := ParseWithResult(c, &Options{Source: MapSource{
+		"PASSWORD":  "password-hidden",
+		"TOKEN":     "token-hidden",
+		"KEYS":      "first-secret,second-hidden",
+		"USER":      "app",
+		"UNEXPOSED": "unexposed-hidden",
+	}, SecretPatterns: []string{}})
+
+	require.Nil(t, err)
+	require.Equal(t, "password-hidden", c.Password.Reveal())
+	require.Equal(t, "token-hidden", c.Token.Reveal())
+	require.Len(t, c.Keys, 2)
+	require.Equal(t, "second-hidden", c.Keys[1].Reveal())
+	require.Equal(t, "", c.unexposed.Reveal())
+	require.Equal(t, maskedValue, result.Values()["Password"])
+
+	c.unexposed = NewSecret("unexposed-hidden")
+
+	jsonData, err := json.Marshal(c)
+	require.Nil(t, err)
+
+	textData, err := c.Password.MarshalText()
+	require.Nil(t, err)
+
+	outputs := []string{
+		fmt.Sprintf("%v", c),
+		fmt.Sprintf("%+v", c),
+		fmt.Sprintf("%#v", c),
+		fmt.Sprintf("%s %q %x", c.Password, c.Password, c.Password),
+		c.Password.String(),
+		c.Password.GoString(),
+		string(jsonData),
+		string(textData),
+	}
+
+	for _, output := range outputs {
+		require.NotContains(t, output, "hidden", output)
+	}
+
+	require.Equal(t, `sec.Secret("******")`, fmt.Sprintf("%#v", c.Password))
+	require.Contains(t, string(jsonData), `"Password":"******"`)
+
+	password := c.Password
+	password.Destroy()
+
+	require.Equal(t, "", c.Password.Reveal())
+
+	var empty Secret
+
+	empty.Destroy()
+	require.Equal(t, "", empty.Reveal())
+}
+
+// Source which waits for context to be done when looking up "SLOW" key.
+type testSlowSource struct {
+	MapSource
+}
+
+func (s testSlowSource) LookupContext(ctx context.Context, info FieldInfo) (string, Origin, bool, error) {
+	if info.Key == "SLOW" {
+		<-ctx.Done()
+
+		return "", Origin{}, false, ctx.Err()
+	}
+
+	return lookupField(ctx, s.MapSource, info)
+}
+
+func TestParseContext(t *testing.T) {
+	t.Parallel()
+
+	type testStruct struct {
+		Fast string
+		Slow string
+	}
+
+	source := testSlowSource{MapSource{"FAST": "fast"}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	s := &testStruct{}
+
+	err := ParseContext(ctx, s, &Options{Source: Layers{Named("slow", source)}})
+
+	var canceledErr *CanceledError
+
+	require.True(t, errors.As(err, &canceledErr))
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+	require.Equal(t, "Slow", canceledErr.Path)
+	require.Equal(t, "SLOW", canceledErr.Key)
+	require.Equal(t, "parsing was canceled while resolving field 'Slow' (SLOW): context deadline exceeded", err.Error())
+	require.Equal(t, "fast", s.Fast)
+
+	// Already canceled context.
+	canceledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = ParseContext(canceledCtx, &testStruct{}, &Options{Source: MapSource{}})
+	require.True(t, errors.As(err, &canceledErr))
+	require.True(t, errors.Is(err, context.Canceled))
+	require.Equal(t, "Fast", canceledErr.Path)
+
+	// Context should be passed when reading keys which don't belong to
+	// fields too.
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err = ParseContext(ctx, &testStruct{}, &Options{Source: source, ProfileVariable: "SLOW"})
+	require.True(t, errors.As(err, &canceledErr))
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+	require.Equal(t, "parsing was canceled while reading 'SLOW': context deadline exceeded", err.Error())
+
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err = ParseContext(ctx, &testStruct{}, &Options{
+		Source:          testSlowSource{MapSource{"FAST": "${SLOW}"}},
+		ExpandVariables: true,
+	})
+	require.True(t, errors.As(err, &canceledErr))
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+	require.Equal(t, "Fast", canceledErr.Path)
+}
+
+// Source which waits for context to be done while loading.
+type testSlowLoader struct {
+	MapSource
+}
+
+func (s testSlowLoader) Load(ctx context.Context) error {
+	<-ctx.Done()
+
+	return errors.New("request failed")
+}
+
+func TestParseContextWhileLoading(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := ParseContext(ctx, &struct{ Port int }{}, &Options{Source: Layers{testSlowLoader{MapSource{}}}})
+
+	var canceledErr *CanceledError
+
+	require.True(t, errors.As(err, &canceledErr))
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+	require.Equal(t, "", canceledErr.Path)
+	require.Equal(t, "parsing was canceled while loading sources: context deadline exceeded", err.Error())
+}
+
+type testContextKey struct{}
+
+type testValidatedStruct struct {
+	Port int
+}
+
+func (s *testValidatedStruct) Validate(ctx context.Context) error {
+	if ctx.Value(testContextKey{}) != "value" {
+		return errors.New("context wasn't passed")
+	}
+
+	if s.Port == 0 {
+		return errors.New("port should be set")
+	}
+
+	return nil
+}
+
+func TestParseValidator(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.WithValue(context.Background(), testContextKey{}, "value")
+
+	err := ParseContext(ctx, &testValidatedStruct{}, &Options{Source: MapSource{"PORT": "8080"}})
+	require.Nil(t, err)
+
+	err = Parse(&testValidatedStruct{}, &Options{Source: MapSource{"PORT": "8080"}})
+	require.EqualError(t, err, "context wasn't passed")
+
+	err = ParseContext(ctx, &testValidatedStruct{}, &Options{Source: MapSource{}})
+	require.EqualError(t, err, "port should be set")
+}
@@ -0,0 +1,77 @@
+package sec
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Value which is printed instead of secrets.
+const maskedValue = "******"
+
+// Default patterns for detecting secret fields by variables names.
+var defaultSecretPatterns = []string{"PASSWORD", "PASSWD", "SECRET", "TOKEN", "KEY", "CREDENTIAL", "PRIVATE"}
+
+// Checks if field should be treated as secret, i.e. has `sec:"secret"`
+// tag, is of Secret type or it's variable name matches one of secret
+// patterns.
+func (p *parser) isSecret(element *field) bool {
+	if element.Tag.Has("secret") || element.Pointer.Type() == secretType {
+		return true
+	}
+
+	return p.matchesSecretPatterns(element.EnvVar)
+}
+
+// Checks if variable's value should be treated as secret, i.e. variable
+// belongs to secret field or it's name matches one of secret patterns.
+func (p *parser) isSecretKey(key string) bool {
+	for _, element := range p.tree {
+		if element.EnvVar == key && element.Secret {
+			return true
+		}
+	}
+
+	return p.matchesSecretPatterns(key)
+}
+
+// Checks if variable's name matches one of secret patterns.
+func (p *parser) matchesSecretPatterns(key string) bool {
+	patterns := p.options.SecretPatterns
+	if patterns == nil {
+		patterns = defaultSecretPatterns
+	}
+
+	for _, pattern := range patterns {
+		if pattern != "" && strings.Contains(key, strings.ToUpper(pattern)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns value suitable for printing: secrets are masked.
+func (f *field) display(value string) string {
+	if f.Secret {
+		return maskedValue
+	}
+
+	return value
+}
+
+// Returns error text suitable for printing. Errors produced by strconv
+// contain parsed value, which is masked for secrets.
+func (f *field) displayError(err error) string {
+	if !f.Secret {
+		return err.Error()
+	}
+
+	var numErr *strconv.NumError
+	if errors.As(err, &numErr) {
+		return fmt.Sprintf("strconv.%s: parsing %s: %s", numErr.Func, maskedValue, numErr.Err.Error())
+	}
+
+	return err.Error()
+}
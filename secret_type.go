@@ -0,0 +1,91 @@
+package sec
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// Type of Secret, used for detecting Secret fields.
+var secretType = reflect.TypeOf(Secret{})
+
+// Secret is a string-like type for secrets that resists accidental
+// logging: String(), GoString(), Format(), MarshalJSON() and
+// MarshalText() return masked value, so secret will not appear in
+// output of log.Printf("%+v", cfg) and similar calls. Actual value
+// can be obtained only with Reveal(). Fields of this type are filled
+// like string fields and always treated as secrets.
+//
+// Copies of Secret share same value, so Destroy() wipes value for
+// every copy.
+type Secret struct {
+	// Value is kept behind a pointer, so even printing of structures
+	// with unexported Secret fields (which fmt can't call methods on)
+	// will output only pointer's address.
+	value *secretValue
+}
+
+// Actual secret's data.
+type secretValue struct {
+	data []byte
+}
+
+// NewSecret creates secret with passed value.
+func NewSecret(value string) Secret {
+	return Secret{value: &secretValue{data: []byte(value)}}
+}
+
+// Reveal returns secret's value.
+func (s Secret) Reveal() string {
+	if s.value == nil {
+		return ""
+	}
+
+	return string(s.value.data)
+}
+
+// Destroy overwrites secret's data with zeroes and removes it, so it
+// won't be kept in memory longer than needed. Secret will be empty
+// after that.
+func (s Secret) Destroy() {
+	if s.value == nil {
+		return
+	}
+
+	for idx := range s.value.data {
+		s.value.data[idx] = 0
+	}
+
+	s.value.data = nil
+}
+
+// String returns masked value.
+func (s Secret) String() string {
+	return maskedValue
+}
+
+// GoString returns masked value for %#v.
+func (s Secret) GoString() string {
+	return "sec.Secret(" + strconv.Quote(maskedValue) + ")"
+}
+
+// Format writes masked value for every verb.
+func (s Secret) Format(state fmt.State, verb rune) {
+	if verb == 'v' && state.Flag('#') {
+		_, _ = state.Write([]byte(s.GoString()))
+
+		return
+	}
+
+	_, _ = state.Write([]byte(maskedValue))
+}
+
+// MarshalJSON returns masked value as JSON string.
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(maskedValue)), nil
+}
+
+// MarshalText returns masked value.
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(maskedValue), nil
+}
@@ -0,0 +1,66 @@
+package sec
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// ReloadOnSignal parses configuration into passed structure (which is
+// never modified after that) and then parses it again into new
+// structure on every SIGHUP until context is done. Configuration is
+// validated and delivered to callback only if parsing and validation
+// succeeded and any field was changed. Changed fields (with secrets
+// masked) and rejected reloads are logged using standard log package.
+// WatchOptions.Interval isn't used. Error is returned only if initial
+// parsing failed.
+func ReloadOnSignal(
+	ctx context.Context, structure interface{}, config *WatchOptions, callback func(update *Update),
+) error {
+	w, err := newWatcher(ctx, structure, config, callback)
+	if err != nil {
+		return err
+	}
+
+	w.runOnSignals(ctx)
+
+	return nil
+}
+
+// Installs SIGHUP handler and parses configuration on every received
+// signal until context is done.
+func (w *watcher) runOnSignals(ctx context.Context) {
+	w.mutex.Lock()
+	w.logChanges = true
+	w.mutex.Unlock()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	go w.handleSignals(ctx, signals)
+}
+
+// Parses configuration on every received signal until context is done.
+func (w *watcher) handleSignals(ctx context.Context, signals chan os.Signal) {
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case <-ctx.Done():
+			printDebug("Reloading on signals stopped: %s", ctx.Err().Error())
+
+			return
+		case received := <-signals:
+			log.Printf("Received %s, reloading configuration", received.String())
+
+			// Every reload was explicitly requested, so every error
+			// should be reported.
+			changed, err := w.check(ctx, true)
+			if !changed && err == nil {
+				log.Println("Configuration wasn't changed")
+			}
+		}
+	}
+}
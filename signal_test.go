@@ -0,0 +1,114 @@
+//go:build !windows
+// +build !windows
+
+// nolint:exhaustruct
+package sec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Buffer which is safe for concurrent usage.
+type testSyncBuffer struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *testSyncBuffer) Write(data []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.buffer.Write(data)
+}
+
+func (b *testSyncBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.buffer.String()
+}
+
+func TestReloadOnSignal(t *testing.T) {
+	var output testSyncBuffer
+
+	log.SetOutput(&output)
+	defer log.SetOutput(os.Stderr)
+
+	path := writeTestFile(t, ".env", "HOST=localhost\nPORT=8080\nPASSWORD=first\n")
+
+	dotenv, err := NewDotenvSource(path)
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updates := make(chan *Update, 10)
+	s := &testWatchedStruct{}
+
+	err = ReloadOnSignal(ctx, s, &WatchOptions{Options: &Options{Source: dotenv}}, func(update *Update) {
+		updates <- update
+	})
+	require.Nil(t, err)
+	require.Equal(t, 8080, s.Port)
+
+	// Changes should be applied only on signal.
+	require.Nil(t, os.WriteFile(path, []byte("HOST=example.com\nPORT=8080\nPASSWORD=second\n"), 0o600))
+	time.Sleep(20 * time.Millisecond)
+	require.Len(t, updates, 0)
+
+	require.Nil(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+
+	update := receiveUpdate(t, updates)
+	require.Equal(t, []string{"Host", "Password"}, update.Changed)
+	require.Equal(t, []string{"HOST", "PASSWORD"}, update.Keys)
+
+	updated, ok := update.Config.(*testWatchedStruct)
+	require.True(t, ok)
+	require.Equal(t, "example.com", updated.Host)
+	require.Equal(t, "localhost", s.Host)
+
+	require.Contains(t, output.String(), "Received hangup, reloading configuration")
+	require.Contains(t, output.String(), "Configuration field 'Host' (HOST) was changed: 'localhost' -> 'example.com'")
+	require.Contains(t, output.String(), "Configuration field 'Password' (PASSWORD) was changed: ******")
+	require.NotContains(t, output.String(), "first")
+	require.NotContains(t, output.String(), "second")
+
+	// Invalid configuration should be rejected.
+	require.Nil(t, os.WriteFile(path, []byte("HOST=example.com\nPORT=0\n"), 0o600))
+	require.Nil(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+
+	waitFor(t, func() bool {
+		return bytes.Contains([]byte(output.String()),
+			[]byte("reload was rejected, last good configuration remains in use: port should be set"))
+	})
+
+	require.Len(t, updates, 0)
+
+	err = ReloadOnSignal(context.Background(), &testWatchedStruct{}, nil, nil)
+	require.True(t, errors.Is(err, errNoCallback))
+}
+
+// Waits for condition to become true.
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for !condition() {
+		if time.Now().After(deadline) {
+			require.FailNow(t, "condition wasn't met")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,121 @@
+package sec
+
+import (
+	"os"
+	"strings"
+)
+
+// Source is a storage of values for fields. Keys are environment
+// variables names composed for fields, e.g. "DATABASE_URI".
+type Source interface {
+	// Lookup returns value for passed key. Second returned value
+	// indicates that key was found.
+	Lookup(key string) (string, bool)
+	// Keys returns all keys available in source. Used for scanning
+	// keys by prefix.
+	Keys() []string
+}
+
+// EnvSource is a source which reads values from operating system's
+// environment. It is used by default.
+type EnvSource struct{}
+
+// Lookup returns value of environment variable.
+func (s EnvSource) Lookup(key string) (string, bool) {
+	return os.LookupEnv(key)
+}
+
+// Keys returns names of all environment variables.
+func (s EnvSource) Keys() []string {
+	return environKeys(os.Environ())
+}
+
+// MapSource is a source which reads values from map.
+type MapSource map[string]string
+
+// NewEnvironSource creates source from list of "KEY=value" strings,
+// like ones returned by os.Environ().
+func NewEnvironSource(environ []string) MapSource {
+	source := make(MapSource, len(environ))
+
+	for _, item := range environ {
+		key, value := splitEnvironItem(item)
+		source[key] = value
+	}
+
+	return source
+}
+
+// Lookup returns value from map.
+func (s MapSource) Lookup(key string) (string, bool) {
+	value, found := s[key]
+
+	return value, found
+}
+
+// Keys returns all keys from map.
+func (s MapSource) Keys() []string {
+	keys := make([]string, 0, len(s))
+
+	for key := range s {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// Returns keys from list of "KEY=value" strings.
+func environKeys(environ []string) []string {
+	keys := make([]string, 0, len(environ))
+
+	for _, item := range environ {
+		key, _ := splitEnvironItem(item)
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// Splits "KEY=value" string into key and value.
+func splitEnvironItem(item string) (string, string) {
+	idx := strings.Index(item, "=")
+	if idx == -1 {
+		return item, ""
+	}
+
+	return item[:idx], item[idx+1:]
+}
+
+// Layers is a source which combines several sources. Sources should be
+// listed from lowest to highest priority, so value will be taken from
+// latest source that has it.
+type Layers []Source
+
+// Lookup returns value from source with highest priority that has it.
+func (s Layers) Lookup(key string) (string, bool) {
+	for idx := len(s) - 1; idx >= 0; idx-- {
+		if value, found := s[idx].Lookup(key); found {
+			return value, true
+		}
+	}
+
+	return "", false
+}
+
+// Keys returns keys from all sources without duplicates.
+func (s Layers) Keys() []string {
+	seen := make(map[string]bool)
+	keys := make([]string, 0)
+
+	for _, source := range s {
+		for _, key := range source.Keys() {
+			if !seen[key] {
+				seen[key] = true
+
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	return keys
+}
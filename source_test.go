@@ -0,0 +1,540 @@
+// nolint:exhaustruct
+package sec
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFromMapSource(t *testing.T) {
+	t.Parallel()
+
+	type testStruct struct {
+		Database struct {
+			URI string
+		}
+		Timeout int
+	}
+
+	c := &testStruct{}
+	err := Parse(c, &Options{Source: MapSource{
+		"DATABASE_URI": "postgres://localhost/app",
+		"TIMEOUT":      "10",
+	}})
+
+	require.Nil(t, err)
+	require.Equal(t, "postgres://localhost/app", c.Database.URI)
+	require.Equal(t, 10, c.Timeout)
+}
+
+func TestParseFromEnvironSource(t *testing.T) {
+	t.Parallel()
+
+	type testStruct struct {
+		Data    string
+		Timeout int
+	}
+
+	source := NewEnvironSource([]string{"DATA=key=value", "TIMEOUT=10", "EMPTY"})
+
+	value, found := source.Lookup("EMPTY")
+	require.True(t, found)
+	require.Equal(t, "", value)
+	require.ElementsMatch(t, []string{"DATA", "TIMEOUT", "EMPTY"}, source.Keys())
+
+	c := &testStruct{}
+	err := Parse(c, &Options{Source: source})
+
+	require.Nil(t, err)
+	require.Equal(t, "key=value", c.Data)
+	require.Equal(t, 10, c.Timeout)
+}
+
+func TestEnvSource(t *testing.T) {
+	t.Setenv("SEC_TEST_ENV_SOURCE", "test")
+
+	value, found := EnvSource{}.Lookup("SEC_TEST_ENV_SOURCE")
+	require.True(t, found)
+	require.Equal(t, "test", value)
+	require.Contains(t, EnvSource{}.Keys(), "SEC_TEST_ENV_SOURCE")
+}
+
+func TestParseLayeredSourcesOrigins(t *testing.T) {
+	t.Parallel()
+
+	type testStruct struct {
+		Database struct {
+			URI     string
+			Options string
+		}
+		Timeout int
+		Debug   bool
+		Name    string
+		Unset   string
+	}
+
+	config := writeTestFile(t, "config.env", "DATABASE_URI=postgres://db/app\nTIMEOUT=10\nNAME=app\n")
+	local := writeTestFile(t, "local.env", "\nTIMEOUT=20\n")
+
+	configSource, err := NewDotenvSource(config)
+	require.Nil(t, err)
+
+	localSource, err := NewDotenvSource(local)
+	require.Nil(t, err)
+
+	c := &testStruct{}
+	result, err := ParseWithResult(c, &Options{Source: Layers{
+		Named("defaults", MapSource{"DATABASE_OPTIONS": "sslmode=disable", "TIMEOUT": "5"}),
+		Named("config", configSource),
+		Named("local", localSource),
+		MapSource{"DEBUG": "true"},
+		Named("overrides", MapSource{"NAME": "overridden"}),
+	}})
+
+	require.Nil(t, err)
+	require.Equal(t, "postgres://db/app", c.Database.URI)
+	require.Equal(t, "sslmode=disable", c.Database.Options)
+	require.Equal(t, 20, c.Timeout)
+	require.True(t, c.Debug)
+	require.Equal(t, "overridden", c.Name)
+
+	origin, found := result.Origin("Timeout")
+	require.True(t, found)
+	require.Equal(t, Origin{Source: "local", Key: "TIMEOUT", File: local, Line: 2}, origin)
+	require.Equal(t, "local "+local+":2 (TIMEOUT)", origin.String())
+
+	_, found = result.Origin("Unset")
+	require.False(t, found)
+
+	require.Equal(t, map[string]Origin{
+		"Database.URI":     {Source: "config", Key: "DATABASE_URI", File: config, Line: 1},
+		"Database.Options": {Source: "defaults", Key: "DATABASE_OPTIONS"},
+		"Timeout":          {Source: "local", Key: "TIMEOUT", File: local, Line: 2},
+		"Debug":            {Source: "map", Key: "DEBUG"},
+		"Name":             {Source: "overrides", Key: "NAME"},
+	}, result.Origins())
+	require.Equal(t, "map (DEBUG)", result.Origins()["Debug"].String())
+}
+
+func TestParseFromJSONSource(t *testing.T) {
+	t.Parallel()
+
+	type testStruct struct {
+		Database struct {
+			URI     string
+			Options string
+			Port    uint16
+		}
+		HTTPTimeout float64
+		Debug       bool
+		Name        string
+		Hosts       string
+		Nothing     string
+	}
+
+	path := writeTestFile(t, "config.json", `{
+	"database": {"uri": "postgres://db/app", "options": "sslmode=disable", "port": 5432},
+	"httpTimeout": 1.5,
+	"debug": true,
+	"name": "app",
+	"hosts": ["first", "second"],
+	"nothing": null
+}`)
+
+	source, err := NewJSONSource(path)
+	require.Nil(t, err)
+
+	c := &testStruct{}
+	result, err := ParseWithResult(c, &Options{Source: Layers{source, MapSource{"NAME": "overridden"}}})
+
+	require.Nil(t, err)
+	require.Equal(t, "postgres://db/app", c.Database.URI)
+	require.Equal(t, "sslmode=disable", c.Database.Options)
+	require.Equal(t, uint16(5432), c.Database.Port)
+	require.Equal(t, 1.5, c.HTTPTimeout)
+	require.True(t, c.Debug)
+	require.Equal(t, "overridden", c.Name)
+	require.Equal(t, "first,second", c.Hosts)
+	require.Equal(t, "", c.Nothing)
+
+	origin, found := result.Origin("Database.Port")
+	require.True(t, found)
+	require.Equal(t, "json "+path+"#/database/port (DATABASE_PORT)", origin.String())
+}
+
+func TestJSONSourceErrors(t *testing.T) {
+	t.Parallel()
+
+	type testStruct struct {
+		Database struct {
+			Port uint16
+		}
+	}
+
+	path := writeTestFile(t, "config.json", `{"database": {"port": 70000}}`)
+
+	source, err := NewJSONSource(path)
+	require.Nil(t, err)
+
+	err = Parse(&testStruct{}, &Options{Source: source, ErrorsAreCritical: true})
+	require.True(t, errors.Is(err, errNotUint16))
+	require.Contains(t, err.Error(), path+"#/database/port")
+
+	path = writeTestFile(t, "config.json", `{"database": {"port": {"number": 1}}}`)
+
+	source, err = NewJSONSource(path)
+	require.Nil(t, err)
+
+	err = Parse(&testStruct{}, &Options{Source: source, ErrorsAreCritical: true})
+	require.True(t, errors.Is(err, errNotUint))
+
+	_, err = NewJSONSource(writeTestFile(t, "config.json", "{\n\"database\": }"))
+	require.True(t, errors.Is(err, errJSONSyntax))
+	require.Contains(t, err.Error(), "config.json:2:")
+
+	_, err = NewJSONSource(writeTestFile(t, "config.json", "[1, 2]"))
+	require.True(t, errors.Is(err, errJSONNotRoot))
+
+	// Arrays with items containing commas can't be represented, but
+	// they are reported only when looked up.
+	path = writeTestFile(t, "config.json", `{"servers": [{"host": "a", "port": 1}], "hosts": ["a", "b,c"], "port": 8080}`)
+
+	source, err = NewJSONSource(path)
+	require.Nil(t, err)
+
+	s := &struct{ Port int }{}
+
+	err = Parse(s, &Options{Source: source})
+	require.Nil(t, err)
+	require.Equal(t, 8080, s.Port)
+
+	err = Parse(&struct{ Hosts []string }{}, &Options{Source: Layers{source}})
+	require.True(t, errors.Is(err, errJSONComma))
+	require.Contains(t, err.Error(), path+"#/hosts/1")
+
+	_, err = NewJSONSource(filepath.Join(t.TempDir(), "not-exists"))
+	require.True(t, errors.Is(err, errJSONRead))
+}
+
+func TestParseFromINISource(t *testing.T) {
+	t.Parallel()
+
+	type testStruct struct {
+		Database struct {
+			URI     string
+			Options string
+			Replica struct {
+				Hosts []string
+				Ports []uint16
+			}
+		}
+		Timeout int
+		Name    string
+		Motd    string
+		Empty   []string
+	}
+
+	path := writeTestFile(t, "config.ini", `; Comment
+timeout = 10 # inline comment
+name = 'my app; literal'
+motd = "Hello,\n\"world\""
+empty = []
+
+[database]
+uri = postgres://localhost/app?sslmode=disable#fragment
+options = "connect_timeout=10"
+
+[database.replica]
+hosts = ["first", 'second', third]
+ports = [
+	5432, # primary
+	5433,
+]
+`)
+
+	source, err := NewINISource(path)
+	require.Nil(t, err)
+
+	c := &testStruct{}
+	result, err := ParseWithResult(c, &Options{Source: Layers{source, MapSource{"TIMEOUT": "20"}}})
+
+	require.Nil(t, err)
+	require.Equal(t, 20, c.Timeout)
+	require.Equal(t, "my app; literal", c.Name)
+	require.Equal(t, "Hello,\n\"world\"", c.Motd)
+	require.Equal(t, []string{}, c.Empty)
+	require.Equal(t, "postgres://localhost/app?sslmode=disable#fragment", c.Database.URI)
+	require.Equal(t, "connect_timeout=10", c.Database.Options)
+	require.Equal(t, []string{"first", "second", "third"}, c.Database.Replica.Hosts)
+	require.Equal(t, []uint16{5432, 5433}, c.Database.Replica.Ports)
+
+	origin, found := result.Origin("Database.Replica.Ports")
+	require.True(t, found)
+	require.Equal(t, "ini "+path+":13 (DATABASE_REPLICA_PORTS)", origin.String())
+}
+
+func TestINISourceSyntaxErrors(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		Data  string
+		Error string
+	}{
+		{"key = value\ninvalid\n", ":2: expected '='"},
+		{"[database\n", ":1: unterminated section name"},
+		{"[database] trailing\n", ":1: unexpected 'trailing' after section name"},
+		{"[data base]\n", ":1: invalid name 'data base'"},
+		{"key = 'value\n", ":1: unterminated single quoted value"},
+		{"key = \"value\n", ":1: unterminated double quoted value"},
+		{"key = \"\\q\"\n", ":1: invalid escape sequence '\\q'"},
+		{"key = \"value\" trailing\n", ":1: unexpected 'trailing' after value"},
+		{"\nkey = [1, 2\n", ":2: unterminated array"},
+		{"key = [1 2]\n", ":1: expected ',' or ']' in array, got '2]'"},
+		{"key = [\"a\", \"b,c\"]\n", ":1: array item contains comma"},
+	}
+
+	for _, testCase := range testCases {
+		path := writeTestFile(t, "config.ini", testCase.Data)
+
+		_, err := NewINISource(path)
+
+		require.NotNil(t, err, testCase.Data)
+		require.True(t, errors.Is(err, errINISyntax))
+		require.Contains(t, err.Error(), path+testCase.Error)
+	}
+
+	_, err := NewINISource(filepath.Join(t.TempDir(), "not-exists"))
+	require.True(t, errors.Is(err, errINIRead))
+}
+
+func TestParseFromDirSource(t *testing.T) {
+	t.Parallel()
+
+	type testStruct struct {
+		Database struct {
+			URI      string
+			Password string
+		}
+		Timeout int
+	}
+
+	// Kubernetes-like layout: files are in timestamped directory,
+	// "..data" is a symlink to it and keys are symlinks to "..data/key".
+	dir := t.TempDir()
+	dataDir := filepath.Join(dir, "..2024_01_01_00_00_00.000000000")
+
+	require.Nil(t, os.Mkdir(dataDir, 0o700))
+	require.Nil(t, os.WriteFile(filepath.Join(dataDir, "database-uri"), []byte("postgres://db/app\n"), 0o600))
+	require.Nil(t, os.WriteFile(filepath.Join(dataDir, "timeout"), []byte("10\r\n"), 0o600))
+	require.Nil(t, os.Symlink(filepath.Base(dataDir), filepath.Join(dir, "..data")))
+	require.Nil(t, os.Symlink("..data/database-uri", filepath.Join(dir, "database-uri")))
+	require.Nil(t, os.Symlink("..data/timeout", filepath.Join(dir, "timeout")))
+	require.Nil(t, os.Mkdir(filepath.Join(dir, "subdirectory"), 0o700))
+
+	// Docker secrets-like regular file.
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "database.password"), []byte("secret\n\n"), 0o600))
+
+	source, err := NewDirSource(dir, nil)
+	require.Nil(t, err)
+	require.ElementsMatch(t, []string{"DATABASE_URI", "DATABASE_PASSWORD", "TIMEOUT"}, source.Keys())
+
+	c := &testStruct{}
+	result, err := ParseWithResult(c, &Options{Source: Layers{source, MapSource{"TIMEOUT": "20"}}})
+
+	require.Nil(t, err)
+	require.Equal(t, "postgres://db/app", c.Database.URI)
+	require.Equal(t, "secret", c.Database.Password)
+	require.Equal(t, 20, c.Timeout)
+
+	origin, found := result.Origin("Database.URI")
+	require.True(t, found)
+	require.Equal(t, Origin{Source: "dir", Key: "DATABASE_URI", File: filepath.Join(dir, "database-uri")}, origin)
+
+	source, err = NewDirSource(dir, &DirOptions{KeyMapper: func(name string) string {
+		if name == "timeout" {
+			return "APP_TIMEOUT"
+		}
+
+		return ""
+	}})
+	require.Nil(t, err)
+	require.Equal(t, []string{"APP_TIMEOUT"}, source.Keys())
+
+	_, err = NewDirSource(dir, &DirOptions{MaxFileSize: 5})
+	require.True(t, errors.Is(err, errFileTooLarge))
+
+	_, err = NewDirSource(filepath.Join(dir, "not-exists"), nil)
+	require.True(t, errors.Is(err, errDirRead))
+}
+
+func TestParseFromCredentialsSource(t *testing.T) {
+	t.Parallel()
+
+	type testStruct struct {
+		Database struct {
+			URI      string
+			Password string `sec:"credential"`
+			Token    string `sec:"credential=api-token"`
+		}
+		Timeout int
+	}
+
+	dir := t.TempDir()
+
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "database_password"), []byte("secret\n"), 0o600))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "api-token"), []byte("token"), 0o600))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "timeout"), []byte("10"), 0o600))
+
+	fallback := MapSource{
+		"DATABASE_URI":      "postgres://db/app",
+		"DATABASE_PASSWORD": "fallback",
+		"DATABASE_TOKEN":    "fallback",
+		"TIMEOUT":           "20",
+	}
+
+	c := &testStruct{}
+	result, err := ParseWithResult(c, &Options{
+		Source: NewCredentialsSource(fallback, &CredentialsOptions{Directory: dir}),
+	})
+
+	require.Nil(t, err)
+	require.Equal(t, "postgres://db/app", c.Database.URI)
+	require.Equal(t, "secret", c.Database.Password)
+	require.Equal(t, "token", c.Database.Token)
+	require.Equal(t, 20, c.Timeout)
+
+	origin, found := result.Origin("Database.Token")
+	require.True(t, found)
+	require.Equal(t, Origin{Source: "credentials", Key: "api-token", File: filepath.Join(dir, "api-token")}, origin)
+
+	// All fields should be looked up in credentials directory.
+	c1 := &testStruct{}
+	err = Parse(c1, &Options{Source: NewCredentialsSource(fallback, &CredentialsOptions{Directory: dir, All: true})})
+
+	require.Nil(t, err)
+	require.Equal(t, "postgres://db/app", c1.Database.URI)
+	require.Equal(t, 10, c1.Timeout)
+
+	// Without credentials directory everything comes from fallback, also
+	// when layered.
+	c2 := &testStruct{}
+	err = Parse(c2, &Options{Source: Layers{
+		MapSource{"TIMEOUT": "5"},
+		Named("systemd", NewCredentialsSource(fallback, &CredentialsOptions{Directory: ""})),
+	}})
+
+	require.Nil(t, err)
+	require.Equal(t, "fallback", c2.Database.Password)
+	require.Equal(t, 20, c2.Timeout)
+
+	// Profile specific credentials should be preferred, also for names
+	// defined in tag.
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "api-token__production"), []byte("production"), 0o600))
+
+	c3 := &testStruct{}
+	err = Parse(c3, &Options{
+		Source: NewCredentialsSource(
+			Layers{fallback, MapSource{"APP_ENV": "production"}}, &CredentialsOptions{Directory: dir},
+		),
+		ProfileVariable: "APP_ENV",
+	})
+
+	require.Nil(t, err)
+	require.Equal(t, "production", c3.Database.Token)
+	require.Equal(t, "secret", c3.Database.Password)
+
+	_, err = ParseWithResult(&testStruct{}, &Options{
+		Source: NewCredentialsSource(fallback, &CredentialsOptions{Directory: dir, MaxFileSize: 2}),
+	})
+	require.True(t, errors.Is(err, errFileTooLarge))
+}
+
+func TestParseProfiles(t *testing.T) {
+	t.Parallel()
+
+	type testStruct struct {
+		Database struct {
+			URI      string
+			Replicas int
+		}
+		HTTPServer struct {
+			Port int
+		}
+		LogLevel string `default:"info" default.production:"warning"`
+		Timeout  int    `default:"10"`
+		Debug    bool
+	}
+
+	ini := writeTestFile(t, "config.ini", `[database]
+uri = postgres://localhost/app
+replicas = 0
+
+[profiles.production.database]
+replicas = 2
+
+[profiles.production.http_server]
+port = 443
+`)
+
+	iniSource, err := NewINISource(ini)
+	require.Nil(t, err)
+
+	json := writeTestFile(t, "config.json", `{"debug": true, "profiles": {"production": {"debug": false}}}`)
+
+	jsonSource, err := NewJSONSource(json)
+	require.Nil(t, err)
+
+	env := MapSource{
+		"APP_ENV":                   "production",
+		"DATABASE_URI":              "postgres://db/app",
+		"DATABASE_URI__STAGING":     "postgres://staging-db/app",
+		"DATABASE_URI__PRODUCTION":  "postgres://production-db/app",
+		"HTTPSERVER_PORT":           "8080",
+		"HTTPSERVER_PORT__STAGING":  "8443",
+		"DATABASE_REPLICAS__LOCAL":  "10",
+		"DATABASE_REPLICAS__SHARED": "20",
+	}
+
+	// Without profile variable profiles aren't used.
+	c := &testStruct{}
+	result, err := ParseWithResult(c, &Options{Source: Layers{iniSource, jsonSource, env}})
+
+	require.Nil(t, err)
+	require.Equal(t, "", result.Profile())
+	require.Equal(t, "postgres://db/app", c.Database.URI)
+	require.Equal(t, 0, c.Database.Replicas)
+	require.Equal(t, "info", c.LogLevel)
+	require.Equal(t, 10, c.Timeout)
+	require.True(t, c.Debug)
+
+	c1 := &testStruct{}
+	result, err = ParseWithResult(c1, &Options{Source: Layers{iniSource, jsonSource, env}, ProfileVariable: "APP_ENV"})
+
+	require.Nil(t, err)
+	require.Equal(t, "production", result.Profile())
+	require.Equal(t, "postgres://production-db/app", c1.Database.URI)
+	require.Equal(t, 2, c1.Database.Replicas)
+	require.Equal(t, 8080, c1.HTTPServer.Port)
+	require.Equal(t, "warning", c1.LogLevel)
+	require.Equal(t, 10, c1.Timeout)
+	require.False(t, c1.Debug)
+
+	origins := result.Origins()
+	require.Equal(t, Origin{Source: "map", Key: "DATABASE_URI__PRODUCTION"}, origins["Database.URI"])
+	require.Equal(t, Origin{Source: "ini", Key: "DATABASE_REPLICAS__PRODUCTION", File: ini, Line: 6},
+		origins["Database.Replicas"])
+	require.Equal(t, Origin{Source: "default.production", Key: "LOGLEVEL"}, origins["LogLevel"])
+	require.Equal(t, Origin{Source: "default", Key: "TIMEOUT"}, origins["Timeout"])
+	require.Equal(t, "json "+json+"#/profiles/production/debug (DEBUG__PRODUCTION)", origins["Debug"].String())
+
+	// INI profile section with underscores in name.
+	_, found := iniSource.Lookup("HTTP_SERVER_PORT__PRODUCTION")
+	require.True(t, found)
+
+	_, err = NewINISource(writeTestFile(t, "config.ini", "[profiles]\nkey = value\n"))
+	require.True(t, errors.Is(err, errINISyntax))
+}
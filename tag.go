@@ -0,0 +1,44 @@
+package sec
+
+import (
+	"strings"
+)
+
+// Name of struct tag with field options.
+const tagName = "sec"
+
+// Options defined in field's tag, like `sec:"file,name=value"`. Keys
+// are options names, values are what was passed after "=" (if any).
+type tagOptions map[string]string
+
+// Parses field's tag.
+func parseTag(tag string) tagOptions {
+	options := make(tagOptions)
+
+	for _, option := range strings.Split(tag, ",") {
+		option = strings.TrimSpace(option)
+		if option == "" {
+			continue
+		}
+
+		name, value := splitEnvironItem(option)
+		options[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+
+	return options
+}
+
+// Has checks if option was defined in tag.
+func (t tagOptions) Has(name string) bool {
+	_, found := t[name]
+
+	return found
+}
+
+// Get returns option's value. Second returned value indicates that
+// option was defined.
+func (t tagOptions) Get(name string) (string, bool) {
+	value, found := t[name]
+
+	return value, found
+}
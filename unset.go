@@ -0,0 +1,46 @@
+package sec
+
+import (
+	"os"
+	"sort"
+)
+
+// Removes environment variables values of secret fields (if UnsetSecrets
+// option is set) and fields with `sec:"unset"` tag were taken from, so
+// they won't be inherited by child processes. Only values that were
+// taken from process environment are removed, variables with same names
+// are kept if values came from other sources.
+func (p *parser) unsetConsumed() error {
+	unset := make(map[string]bool)
+
+	for _, element := range p.tree {
+		if !element.Tag.Has("unset") && !(p.options.UnsetSecrets && element.Secret) {
+			continue
+		}
+
+		origin, found := p.origins[element.Path]
+		if !found || !origin.environment || origin.Key == "" || unset[origin.Key] {
+			continue
+		}
+
+		value, found := os.LookupEnv(origin.Key)
+		if !found {
+			continue
+		}
+
+		printDebug("Unsetting '%s' environment variable consumed by '%s'", origin.Key, element.Path)
+
+		err := os.Unsetenv(origin.Key)
+		if err != nil {
+			return err
+		}
+
+		unset[origin.Key] = true
+		p.unset = append(p.unset, origin.Key)
+		p.consumed[origin.Key] = value
+	}
+
+	sort.Strings(p.unset)
+
+	return nil
+}
@@ -0,0 +1,26 @@
+package sec
+
+import (
+	"context"
+)
+
+// Validator is implemented by structures which check values after
+// parsing, e.g. that required fields are set. Validate is called after
+// successful parsing with context passed to ParseContext(), it's error
+// is returned as is.
+type Validator interface {
+	Validate(ctx context.Context) error
+}
+
+// Calls Validate method of passed structure if it implements Validator
+// interface.
+func (p *parser) validate(structure interface{}) error {
+	validator, ok := structure.(Validator)
+	if !ok {
+		return nil
+	}
+
+	printDebug("Validating parsed structure")
+
+	return validator.Validate(p.ctx)
+}
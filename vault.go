@@ -0,0 +1,317 @@
+package sec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default timeout for requests to remote storages.
+const defaultHTTPTimeout = 30 * time.Second
+
+var (
+	errVaultAuth     = errors.New("vault authentication failed")
+	errVaultConfig   = errors.New("invalid vault source configuration")
+	errVaultNotFound = errors.New("vault secret not found")
+	errVaultRequest  = errors.New("vault request failed")
+)
+
+// VaultOptions represents configuration for VaultSource.
+type VaultOptions struct {
+	// Address is Vault's address, like "https://vault.example.com:8200".
+	Address string
+	// Token is a Vault token. If empty, then AppRole login with RoleID
+	// and SecretID will be performed.
+	Token string
+	// RoleID is AppRole role ID.
+	RoleID string
+	// SecretID is AppRole secret ID.
+	SecretID string
+	// AppRoleMount is a path AppRole auth method is mounted at.
+	// Defaults to "approle".
+	AppRoleMount string
+	// Mount is a path KV v2 secrets engine is mounted at. Defaults to
+	// "secret".
+	Mount string
+	// Path is a path to secret in KV engine, like "apps/billing".
+	Path string
+	// KeyMapper converts secret's key into source key. By default key
+	// will be uppercased with dashes and dots replaced with
+	// underscores, so "database-password" will provide
+	// "DATABASE_PASSWORD" key.
+	KeyMapper func(name string) string
+	// HTTPClient is a client used for requests. Defaults to client with
+	// 30 seconds timeout.
+	HTTPClient *http.Client
+}
+
+// VaultSource is a source which reads secret from HashiCorp Vault's KV
+// v2 secrets engine. Secret is read once per parsing, every key of it
+// becomes source's key. Non-string values are provided as JSON.
+type VaultSource struct {
+	options VaultOptions
+
+	mutex  sync.RWMutex
+	values map[string]string
+	names  map[string]string
+}
+
+// NewVaultSource creates new Vault source. Nothing is requested until
+// parsing, so source can be created before Vault becomes available.
+func NewVaultSource(config *VaultOptions) (*VaultSource, error) {
+	options := VaultOptions{}
+	if config != nil {
+		options = *config
+	}
+
+	if options.Address == "" || options.Path == "" {
+		return nil, fmt.Errorf("%w: address and path should be set", errVaultConfig)
+	}
+
+	if options.Token == "" && (options.RoleID == "" || options.SecretID == "") {
+		return nil, fmt.Errorf("%w: either token or AppRole role ID and secret ID should be set", errVaultConfig)
+	}
+
+	options.Address = strings.TrimRight(options.Address, "/")
+	options.Path = strings.Trim(options.Path, "/")
+
+	if options.AppRoleMount == "" {
+		options.AppRoleMount = "approle"
+	}
+
+	if options.Mount == "" {
+		options.Mount = "secret"
+	}
+
+	if options.KeyMapper == nil {
+		options.KeyMapper = defaultDirKeyMapper
+	}
+
+	if options.HTTPClient == nil {
+		options.HTTPClient = &http.Client{Timeout: defaultHTTPTimeout}
+	}
+
+	return &VaultSource{
+		options: options,
+		values:  make(map[string]string),
+		names:   make(map[string]string),
+	}, nil
+}
+
+// Load logs in (if AppRole is used) and reads secret.
+func (s *VaultSource) Load(ctx context.Context) error {
+	token := s.options.Token
+	if token == "" {
+		var err error
+
+		token, err = s.login(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	var response struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+
+	err := s.request(ctx, http.MethodGet, "/v1/"+s.options.Mount+"/data/"+s.options.Path, token, nil, &response)
+	if errors.Is(err, errVaultNotFound) {
+		return fmt.Errorf("%w: '%s'", errVaultNotFound, s.secretPath())
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// Deleted secrets are returned with null data.
+	if response.Data.Data == nil {
+		return fmt.Errorf("%w: '%s' was deleted", errVaultNotFound, s.secretPath())
+	}
+
+	values := make(map[string]string, len(response.Data.Data))
+	names := make(map[string]string, len(response.Data.Data))
+
+	for name, value := range response.Data.Data {
+		key := s.options.KeyMapper(name)
+		if key == "" {
+			continue
+		}
+
+		if str, ok := value.(string); ok {
+			values[key] = str
+		} else {
+			data, err := json.Marshal(value)
+			if err != nil {
+				return fmt.Errorf("%w: '%s' in '%s': %s", errVaultRequest, name, s.secretPath(), err.Error())
+			}
+
+			values[key] = string(data)
+		}
+
+		names[key] = name
+	}
+
+	s.mutex.Lock()
+	s.values = values
+	s.names = names
+	s.mutex.Unlock()
+
+	printDebug("Read %d keys from vault secret '%s'", len(values), s.secretPath())
+
+	return nil
+}
+
+// Lookup returns value of secret's key.
+func (s *VaultSource) Lookup(key string) (string, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	value, found := s.values[key]
+
+	return value, found
+}
+
+// Keys returns keys of secret.
+func (s *VaultSource) Keys() []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	keys := make([]string, 0, len(s.values))
+
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// Origin returns path to secret and name of key in it.
+func (s *VaultSource) Origin(key string) (Origin, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	name, found := s.names[key]
+	if !found {
+		return Origin{}, false
+	}
+
+	return Origin{Source: "vault", Key: key, File: s.secretPath(), Pointer: "/" + name}, true
+}
+
+// Logs in using AppRole and returns token.
+func (s *VaultSource) login(ctx context.Context) (string, error) {
+	body, err := json.Marshal(map[string]string{
+		"role_id":   s.options.RoleID,
+		"secret_id": s.options.SecretID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var response struct {
+		Auth struct {
+			ClientToken string `json:"client_token"`
+		} `json:"auth"`
+	}
+
+	err = s.request(ctx, http.MethodPost, "/v1/auth/"+s.options.AppRoleMount+"/login", "", body, &response)
+	if err != nil {
+		// Vault responds to invalid credentials with 400 or 404 errors
+		// depending on version. Other errors (like connection or server
+		// errors) are reported as is.
+		var statusErr *vaultStatusError
+		if errors.Is(err, errVaultNotFound) || (errors.As(err, &statusErr) && statusErr.status == http.StatusBadRequest) {
+			err = newRequestError(errVaultAuth, fmt.Errorf("approle login: %w", err))
+		}
+
+		return "", err
+	}
+
+	if response.Auth.ClientToken == "" {
+		return "", fmt.Errorf("%w: approle login response doesn't contain token", errVaultAuth)
+	}
+
+	return response.Auth.ClientToken, nil
+}
+
+// Performs request to Vault and decodes response into passed value.
+func (s *VaultSource) request(ctx context.Context, method, path, token string, body []byte, result interface{}) error {
+	request, err := http.NewRequestWithContext(ctx, method, s.options.Address+path, bytes.NewReader(body))
+	if err != nil {
+		return newRequestError(errVaultRequest, err)
+	}
+
+	if token != "" {
+		request.Header.Set("X-Vault-Token", token)
+	}
+
+	response, err := s.options.HTTPClient.Do(request)
+	if err != nil {
+		return newRequestError(errVaultRequest, err)
+	}
+
+	defer response.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(response.Body, defaultMaxFileSize))
+	if err != nil {
+		return newRequestError(errVaultRequest, err)
+	}
+
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("%w: %s %s: %s", errVaultAuth, method, path, vaultErrors(data))
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %s %s", errVaultNotFound, method, path)
+	default:
+		return newRequestError(errVaultRequest, &vaultStatusError{
+			status: response.StatusCode,
+			text:   fmt.Sprintf("%s %s: status %d: %s", method, path, response.StatusCode, vaultErrors(data)),
+		})
+	}
+
+	err = json.Unmarshal(data, result)
+	if err != nil {
+		return fmt.Errorf("%w: invalid response: %s", errVaultRequest, err.Error())
+	}
+
+	return nil
+}
+
+// Error of request which was responded with unexpected status.
+type vaultStatusError struct {
+	status int
+	text   string
+}
+
+// Error returns request and status with Vault's errors.
+func (e *vaultStatusError) Error() string {
+	return e.text
+}
+
+// Returns secret's path for messages.
+func (s *VaultSource) secretPath() string {
+	return s.options.Mount + "/" + s.options.Path
+}
+
+// Extracts errors from Vault's response.
+func vaultErrors(data []byte) string {
+	var response struct {
+		Errors []string `json:"errors"`
+	}
+
+	if json.Unmarshal(data, &response) != nil || len(response.Errors) == 0 {
+		return "no errors reported"
+	}
+
+	return strings.Join(response.Errors, "; ")
+}
@@ -0,0 +1,211 @@
+// nolint:exhaustruct
+package sec
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Starts stand-in of Vault API with AppRole auth and KV v2 secret at
+// "secret/apps/billing". Returns server and counter of secret reads.
+func newTestVault(t *testing.T) (*httptest.Server, *int32) {
+	t.Helper()
+
+	var reads int32
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/v1/auth/approle/login", func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]string
+
+		_ = json.NewDecoder(r.Body).Decode(&request)
+
+		if r.Method != http.MethodPost || request["role_id"] != "role" || request["secret_id"] != "secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"errors":["invalid role or secret ID"]}`))
+
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"auth":{"client_token":"approle-token"}}`))
+	})
+
+	mux.HandleFunc("/v1/", func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("X-Vault-Token")
+		if token != "root-token" && token != "approle-token" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v1/secret/data/apps/billing":
+			atomic.AddInt32(&reads, 1)
+
+			_, _ = w.Write([]byte(`{"data":{"data":{"database-password":"pa$$","port":8080,"debug":true},` +
+				`"metadata":{"version":3}}}`))
+		case "/v1/secret/data/apps/deleted":
+			_, _ = w.Write([]byte(`{"data":{"data":null,"metadata":{"version":2}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[]}`))
+		}
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server, &reads
+}
+
+func TestParseFromVaultSource(t *testing.T) {
+	t.Parallel()
+
+	type testStruct struct {
+		Database struct {
+			Password string
+		}
+		Port  int
+		Debug bool
+		Host  string
+	}
+
+	server, reads := newTestVault(t)
+
+	for _, options := range []*VaultOptions{
+		{Address: server.URL, Path: "apps/billing", Token: "root-token"},
+		{Address: server.URL + "/", Path: "/apps/billing/", RoleID: "role", SecretID: "secret"},
+	} {
+		source, err := NewVaultSource(options)
+		require.Nil(t, err)
+
+		// Nothing should be requested before parsing.
+		_, found := source.Lookup("PORT")
+		require.False(t, found)
+
+		s := &testStruct{}
+
+		result, err := ParseWithResult(s, &Options{
+			Source: Layers{MapSource{"HOST": "localhost"}, source},
+		})
+		require.Nil(t, err)
+		require.Equal(t, "pa$$", s.Database.Password)
+		require.Equal(t, 8080, s.Port)
+		require.True(t, s.Debug)
+		require.Equal(t, "localhost", s.Host)
+
+		origin, found := result.Origin("Database.Password")
+		require.True(t, found)
+		require.Equal(t, "vault secret/apps/billing#/database-password (DATABASE_PASSWORD)", origin.String())
+	}
+
+	require.Equal(t, int32(2), atomic.LoadInt32(reads))
+
+	// Secret should be read once per parsing.
+	source, err := NewVaultSource(&VaultOptions{Address: server.URL, Path: "apps/billing", Token: "root-token"})
+	require.Nil(t, err)
+
+	err = Parse(&testStruct{}, &Options{Source: Named("secrets", source)})
+	require.Nil(t, err)
+	require.Equal(t, int32(3), atomic.LoadInt32(reads))
+
+	err = Parse(&testStruct{}, &Options{Source: source})
+	require.Nil(t, err)
+	require.Equal(t, int32(4), atomic.LoadInt32(reads))
+}
+
+func TestVaultSourceErrors(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newTestVault(t)
+
+	testCases := []struct {
+		Options *VaultOptions
+		Error   error
+		Text    string
+	}{
+		{&VaultOptions{Address: server.URL, Path: "apps/billing", Token: "wrong"}, errVaultAuth, "permission denied"},
+		{
+			&VaultOptions{Address: server.URL, Path: "apps/billing", RoleID: "role", SecretID: "wrong"},
+			errVaultAuth, "approle login",
+		},
+		{
+			&VaultOptions{Address: server.URL, Path: "apps/missing", Token: "root-token"},
+			errVaultNotFound, "'secret/apps/missing'",
+		},
+		{&VaultOptions{Address: server.URL, Path: "apps/deleted", Token: "root-token"}, errVaultNotFound, "was deleted"},
+		{
+			&VaultOptions{Address: server.URL, Path: "apps/billing", Mount: "kv", Token: "root-token"},
+			errVaultNotFound, "'kv/apps/billing'",
+		},
+		{
+			&VaultOptions{Address: "http://127.0.0.1:1", Path: "apps/billing", Token: "root-token"},
+			errVaultRequest, "127.0.0.1:1",
+		},
+	}
+
+	for _, testCase := range testCases {
+		source, err := NewVaultSource(testCase.Options)
+		require.Nil(t, err)
+
+		err = Parse(&struct{ Port int }{}, &Options{Source: source})
+
+		require.True(t, errors.Is(err, testCase.Error), err)
+		require.Contains(t, err.Error(), testCase.Text)
+	}
+
+	// Only invalid credentials should be reported as authentication
+	// errors.
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"errors":["internal error"]}`))
+	}))
+	defer broken.Close()
+
+	source, err := NewVaultSource(&VaultOptions{
+		Address: broken.URL, Path: "apps/billing", RoleID: "role", SecretID: "secret",
+	})
+	require.Nil(t, err)
+
+	err = source.Load(context.Background())
+	require.True(t, errors.Is(err, errVaultRequest))
+	require.False(t, errors.Is(err, errVaultAuth))
+	require.Contains(t, err.Error(), "status 500: internal error")
+
+	source, err = NewVaultSource(&VaultOptions{
+		Address: server.URL, Path: "apps/billing", RoleID: "role", SecretID: "secret",
+	})
+	require.Nil(t, err)
+
+	canceledCtx, cancelLogin := context.WithCancel(context.Background())
+	cancelLogin()
+
+	err = source.Load(canceledCtx)
+	require.True(t, errors.Is(err, context.Canceled))
+	require.False(t, errors.Is(err, errVaultAuth))
+
+	// Context errors should be kept in chain.
+	source, err = NewVaultSource(&VaultOptions{Address: server.URL, Path: "apps/billing", Token: "root-token"})
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = source.Load(ctx)
+	require.True(t, errors.Is(err, errVaultRequest))
+	require.True(t, errors.Is(err, context.Canceled))
+
+	_, err = NewVaultSource(&VaultOptions{Path: "apps/billing", Token: "root-token"})
+	require.True(t, errors.Is(err, errVaultConfig))
+
+	_, err = NewVaultSource(&VaultOptions{Address: server.URL, Path: "apps/billing", RoleID: "role"})
+	require.True(t, errors.Is(err, errVaultConfig))
+}
@@ -0,0 +1,384 @@
+package sec
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Default interval between checks for changes.
+const defaultWatchInterval = 5 * time.Second
+
+var errNoCallback = errors.New("callback should be passed")
+
+// WatchOptions represents configuration for Watch().
+type WatchOptions struct {
+	// Options are parsing options.
+	Options *Options
+	// Interval is an interval between checks for changes. Sources which
+	// implement ChangeDetector interface (file sources, key/value
+	// storage) are parsed again only when they report changes, others
+	// are parsed on every check. Defaults to 5 seconds.
+	Interval time.Duration
+	// New creates new structures for parsing into. By default new zero
+	// structure of same type as passed to Watch() is created, so it
+	// should be set if structure contains values assigned before
+	// parsing (like interface values). Should always return pointers
+	// of same type.
+	New func() interface{}
+	// OnError is called when reload was rejected because of parsing or
+	// validation error. Last good configuration remains in use. Same
+	// error isn't reported again until sources are fixed.
+	OnError func(err error)
+}
+
+// Update describes new configuration delivered by Watch().
+type Update struct {
+	// Config is a pointer to new structure.
+	Config interface{}
+	// Changed is a sorted list of paths of changed fields, like
+	// "Database.URI".
+	Changed []string
+	// Keys is a sorted list of keys (environment variables names) of
+	// changed fields, like "DATABASE_URI".
+	Keys []string
+	// Result contains information about parsing.
+	Result *Result
+}
+
+// Watch parses configuration into passed structure (which is never
+// modified after that) and then watches sources for changes until
+// context is done. On changes configuration is parsed into new
+// structure and delivered to callback if parsing and validation
+// succeeded and any field was changed. Invalid configurations are
+// rejected and reported to OnError, so last good configuration remains
+// in use. Callback is called from single goroutine. Error is returned
+// only if initial parsing failed.
+func Watch(ctx context.Context, structure interface{}, config *WatchOptions, callback func(update *Update)) error {
+	w, err := newWatcher(ctx, structure, config, callback)
+	if err != nil {
+		return err
+	}
+
+	go w.run(ctx)
+
+	return nil
+}
+
+// Creates new watcher and parses configuration into passed structure.
+func newWatcher(
+	ctx context.Context, structure interface{}, config *WatchOptions, callback func(update *Update),
+) (*watcher, error) {
+	if callback == nil {
+		return nil, errNoCallback
+	}
+
+	options := WatchOptions{}
+	if config != nil {
+		options = *config
+	}
+
+	if options.Interval <= 0 {
+		options.Interval = defaultWatchInterval
+	}
+
+	if options.New == nil {
+		structType := reflect.TypeOf(structure)
+		if structType == nil || structType.Kind() != reflect.Ptr {
+			return nil, errNotPTR
+		}
+
+		options.New = func() interface{} {
+			return reflect.New(structType.Elem()).Interface()
+		}
+	}
+
+	p := newParser(ctx, options.Options)
+
+	err := p.parse(structure)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &watcher{
+		options:  options,
+		callback: callback,
+		source:   p.source,
+		consumed: p.consumed,
+	}
+
+	w.rememberKeys(p)
+	w.remember(p)
+
+	return w, nil
+}
+
+// State of Watch() run.
+type watcher struct {
+	// Serializes reloads.
+	mutex sync.Mutex
+
+	options  WatchOptions
+	callback func(update *Update)
+	source   Source
+	// Values of environment variables that were unset after parsing
+	// (see Options.UnsetSecrets). They aren't in environment anymore, so
+	// they are used on reloads instead.
+	consumed map[string]string
+	// Fingerprints of last good configuration's values.
+	fingerprints map[string][sha256.Size]byte
+	// Values of last good configuration with secrets masked.
+	values map[string]string
+	// Keys (environment variables names) of fields.
+	keys map[string]string
+	// Log changes and rejected reloads using standard log package.
+	logChanges bool
+	// Text of last reported error, used for reporting errors once if
+	// sources weren't fixed.
+	lastError string
+}
+
+// Checks for changes until context is done.
+func (w *watcher) run(ctx context.Context) {
+	ticker := time.NewTicker(w.options.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			printDebug("Watching stopped: %s", ctx.Err().Error())
+
+			return
+		case <-ticker.C:
+			_, _ = w.check(ctx, false)
+		}
+	}
+}
+
+// Reloads configuration and reports errors. If reload was forced, then
+// error is reported even if it was reported before. Returns true if
+// configuration was changed and error if reload was rejected.
+func (w *watcher) check(ctx context.Context, force bool) (bool, error) {
+	changed := force
+
+	var err error
+
+	// Detecting changes might take long (e.g. blocking queries to
+	// key/value storage), so it is done without locking and doesn't
+	// block forced reloads.
+	if !force {
+		changed, err = sourceChanged(ctx, w.source)
+		if err != nil {
+			err = fmt.Errorf("failed to check sources for changes: %w", err)
+		}
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if force {
+		w.lastError = ""
+	}
+
+	var update *Update
+
+	if err == nil && changed {
+		update, err = w.reload(ctx)
+	}
+
+	if err != nil {
+		w.reject(ctx, err)
+
+		return false, err
+	}
+
+	w.lastError = ""
+
+	return update != nil, nil
+}
+
+// Parses configuration again. Returns update if configuration was
+// changed. Should be called with mutex locked.
+func (w *watcher) reload(ctx context.Context) (*Update, error) {
+	structure := w.options.New()
+
+	p := newParser(ctx, w.options.Options)
+
+	if len(w.consumed) != 0 {
+		p.source = Layers{p.source, Named("env", MapSource(w.consumed))}
+	}
+
+	err := p.parse(structure)
+	if err != nil {
+		return nil, err
+	}
+
+	// Variables might be set again and unset by this parsing.
+	for key, value := range p.consumed {
+		w.consumed[key] = value
+	}
+
+	paths := changedPaths(w.fingerprints, p.fingerprints())
+	if len(paths) == 0 {
+		printDebug("Configuration was parsed again, but nothing was changed")
+
+		return nil, nil
+	}
+
+	printDebug("Configuration was reloaded, changed fields: %v", paths)
+
+	w.rememberKeys(p)
+
+	update := &Update{Config: structure, Changed: paths, Keys: w.changedKeys(paths), Result: p.result()}
+
+	if w.logChanges {
+		w.logChanged(paths, update.Result.values)
+	}
+
+	w.remember(p)
+
+	w.callback(update)
+
+	return update, nil
+}
+
+// Remembers parsed configuration as last good one.
+func (w *watcher) remember(p *parser) {
+	w.fingerprints = p.fingerprints()
+	w.values = p.values()
+}
+
+// Remembers keys of parsed fields. Keys of fields that were removed (e.g.
+// map elements) are kept for describing changes.
+func (w *watcher) rememberKeys(p *parser) {
+	if w.keys == nil {
+		w.keys = make(map[string]string, len(p.tree))
+	}
+
+	for _, element := range p.tree {
+		w.keys[element.Path] = element.EnvVar
+	}
+}
+
+// Reports rejected reload.
+func (w *watcher) reject(ctx context.Context, err error) {
+	// Errors caused by stopping aren't interesting.
+	if ctx.Err() != nil || err.Error() == w.lastError {
+		return
+	}
+
+	w.lastError = err.Error()
+
+	printDebug("Configuration reload was rejected: %s", err.Error())
+
+	if w.logChanges {
+		log.Printf("Configuration reload was rejected, last good configuration remains in use: %s", err.Error())
+	}
+
+	if w.options.OnError != nil {
+		w.options.OnError(err)
+	}
+}
+
+// Returns sorted keys of changed fields.
+func (w *watcher) changedKeys(paths []string) []string {
+	result := make([]string, 0, len(paths))
+	seen := make(map[string]bool, len(paths))
+
+	for _, path := range paths {
+		key := w.keys[path]
+		if key != "" && !seen[key] {
+			seen[key] = true
+
+			result = append(result, key)
+		}
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+// Logs changed fields with previous and new values. Values of secrets
+// are masked.
+func (w *watcher) logChanged(paths []string, values map[string]string) {
+	for _, path := range paths {
+		key := w.keys[path]
+
+		previous, existed := w.values[path]
+		if !existed {
+			previous = "<none>"
+		}
+
+		current, exists := values[path]
+		if !exists {
+			current = "<none>"
+		}
+
+		if previous == maskedValue && current == maskedValue {
+			log.Printf("Configuration field '%s' (%s) was changed: %s", path, key, maskedValue)
+		} else {
+			log.Printf("Configuration field '%s' (%s) was changed: '%s' -> '%s'", path, key, previous, current)
+		}
+	}
+}
+
+// Returns hashes of parsed fields values keyed by fields paths. Hashes
+// are used instead of values, so secrets aren't kept in one more place.
+func (p *parser) fingerprints() map[string][sha256.Size]byte {
+	fingerprints := make(map[string][sha256.Size]byte, len(p.tree))
+
+	for _, element := range p.tree {
+		fingerprints[element.Path] = sha256.Sum256([]byte(fingerprintValue(element.Pointer)))
+	}
+
+	return fingerprints
+}
+
+// Returns representation of value for fingerprinting. Secrets are
+// revealed, because they are masked when formatted.
+func fingerprintValue(value reflect.Value) string {
+	if value.Type() == secretType {
+		// nolint:forcetypeassert
+		return fmt.Sprintf("%q", value.Interface().(Secret).Reveal())
+	}
+
+	if value.Kind() == reflect.Slice && value.Type().Elem() == secretType {
+		result := "["
+
+		for idx := 0; idx < value.Len(); idx++ {
+			result += fingerprintValue(value.Index(idx)) + ","
+		}
+
+		return result + "]"
+	}
+
+	return fmt.Sprintf("%#v", value.Interface())
+}
+
+// Returns sorted paths of fields which were changed, added or removed.
+func changedPaths(previous, current map[string][sha256.Size]byte) []string {
+	paths := make([]string, 0)
+
+	for path, fingerprint := range current {
+		if previousFingerprint, found := previous[path]; !found || previousFingerprint != fingerprint {
+			paths = append(paths, path)
+		}
+	}
+
+	for path := range previous {
+		if _, found := current[path]; !found {
+			paths = append(paths, path)
+		}
+	}
+
+	sort.Strings(paths)
+
+	return paths
+}
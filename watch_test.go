@@ -0,0 +1,213 @@
+// nolint:exhaustruct
+package sec
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testWatchedStruct struct {
+	Host     string
+	Port     int
+	Password Secret
+}
+
+func (s *testWatchedStruct) Validate(ctx context.Context) error {
+	if s.Port == 0 {
+		return errors.New("port should be set")
+	}
+
+	return nil
+}
+
+func TestWatch(t *testing.T) {
+	t.Parallel()
+
+	path := writeTestFile(t, ".env", "HOST=localhost\nPORT=8080\nPASSWORD=first\n")
+
+	dotenv, err := NewDotenvSource(path)
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updates := make(chan *Update, 10)
+	errs := make(chan error, 10)
+
+	s := &testWatchedStruct{}
+
+	err = Watch(ctx, s, &WatchOptions{
+		Options:  &Options{Source: Layers{MapSource{"HOST": "example.com"}, dotenv}},
+		Interval: 10 * time.Millisecond,
+		OnError:  func(err error) { errs <- err },
+	}, func(update *Update) { updates <- update })
+	require.Nil(t, err)
+	require.Equal(t, "localhost", s.Host)
+	require.Equal(t, 8080, s.Port)
+
+	// Changes of secrets should be noticed too.
+	replaceTestFile(t, path, []byte("HOST=localhost\nPORT=9090\nPASSWORD=second\n"))
+
+	update := receiveUpdate(t, updates)
+	require.Equal(t, []string{"Password", "Port"}, update.Changed)
+
+	updated, ok := update.Config.(*testWatchedStruct)
+	require.True(t, ok)
+	require.Equal(t, 9090, updated.Port)
+	require.Equal(t, "second", updated.Password.Reveal())
+	require.Equal(t, maskedValue, update.Result.Values()["Password"])
+
+	// Passed structure should not be modified.
+	require.Equal(t, 8080, s.Port)
+	require.Equal(t, "first", s.Password.Reveal())
+
+	// Invalid configurations should be rejected.
+	replaceTestFile(t, path, []byte("HOST=localhost\nPORT=0\nPASSWORD=second\n"))
+	require.EqualError(t, receiveError(t, errs), "port should be set")
+
+	replaceTestFile(t, path, []byte("HOST=localhost\nPORT=9090\nPASSWORD=\"second\n"))
+	require.True(t, errors.Is(receiveError(t, errs), errDotenvSyntax))
+
+	// Configuration is compared with last good one, so fixed file
+	// which provides same values doesn't produce update.
+	replaceTestFile(t, path, []byte("HOST=localhost\nPORT=9090\nPASSWORD=second\n"))
+	replaceTestFile(t, path, []byte("HOST=localhost\nPORT=9091\nPASSWORD=second\n"))
+
+	update = receiveUpdate(t, updates)
+	require.Equal(t, []string{"Port"}, update.Changed)
+
+	// Nothing should be delivered after stopping.
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+
+	replaceTestFile(t, path, []byte("HOST=localhost\nPORT=1\n"))
+	time.Sleep(50 * time.Millisecond)
+
+	require.Len(t, updates, 0)
+	require.Len(t, errs, 0)
+}
+
+func TestWatchErrors(t *testing.T) {
+	t.Parallel()
+
+	err := Watch(context.Background(), &testWatchedStruct{}, nil, nil)
+	require.True(t, errors.Is(err, errNoCallback))
+
+	err = Watch(context.Background(), testWatchedStruct{}, nil, func(*Update) {})
+	require.True(t, errors.Is(err, errNotPTR))
+
+	err = Watch(context.Background(), &testWatchedStruct{}, &WatchOptions{
+		Options: &Options{Source: MapSource{}},
+	}, func(*Update) {})
+	require.EqualError(t, err, "port should be set")
+}
+
+func TestFileSourcesChanges(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	jsonPath := filepath.Join(dir, "config.json")
+	iniPath := filepath.Join(dir, "config.ini")
+	valuesDir := filepath.Join(dir, "values")
+
+	require.Nil(t, os.WriteFile(jsonPath, []byte(`{"port": 8080}`), 0o600))
+	require.Nil(t, os.WriteFile(iniPath, []byte("port = 8080\n"), 0o600))
+	require.Nil(t, os.Mkdir(valuesDir, 0o700))
+	require.Nil(t, os.WriteFile(filepath.Join(valuesDir, "port"), []byte("8080"), 0o600))
+
+	jsonSource, err := NewJSONSource(jsonPath)
+	require.Nil(t, err)
+
+	iniSource, err := NewINISource(iniPath)
+	require.Nil(t, err)
+
+	dirSource, err := NewDirSource(valuesDir, nil)
+	require.Nil(t, err)
+
+	sources := []Source{jsonSource, iniSource, dirSource}
+
+	for _, source := range sources {
+		changed, err := sourceChanged(context.Background(), source)
+		require.Nil(t, err)
+		require.False(t, changed)
+	}
+
+	// Modification time change without content change isn't a change.
+	later := time.Now().Add(time.Minute)
+	require.Nil(t, os.Chtimes(jsonPath, later, later))
+
+	changed, err := jsonSource.Changed(context.Background())
+	require.Nil(t, err)
+	require.False(t, changed)
+
+	require.Nil(t, os.WriteFile(jsonPath, []byte(`{"port": 9090}`), 0o600))
+	require.Nil(t, os.WriteFile(iniPath, []byte("port = 9090\n"), 0o600))
+	require.Nil(t, os.WriteFile(filepath.Join(valuesDir, "host"), []byte("localhost"), 0o600))
+
+	for _, source := range sources {
+		changed, err := sourceChanged(context.Background(), source)
+		require.Nil(t, err)
+		require.True(t, changed, "%T", source)
+	}
+
+	s := &struct {
+		Host string
+		Port int
+	}{}
+
+	err = Parse(s, &Options{Source: Layers{dirSource, iniSource, jsonSource}})
+	require.Nil(t, err)
+	require.Equal(t, "localhost", s.Host)
+	require.Equal(t, 9090, s.Port)
+
+	changed, err = Layers{dirSource, iniSource, jsonSource}.Changed(context.Background())
+	require.Nil(t, err)
+	require.False(t, changed)
+
+	changed, err = Layers{jsonSource, MapSource{}}.Changed(context.Background())
+	require.Nil(t, err)
+	require.True(t, changed, "sources without changes detection should be treated as changed")
+}
+
+// Replaces file atomically, so watcher won't read partially written
+// file.
+func replaceTestFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+
+	tmpPath := path + ".tmp"
+
+	require.Nil(t, os.WriteFile(tmpPath, data, 0o600))
+	require.Nil(t, os.Rename(tmpPath, path))
+}
+
+func receiveUpdate(t *testing.T, updates chan *Update) *Update {
+	t.Helper()
+
+	select {
+	case update := <-updates:
+		return update
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "update wasn't received")
+	}
+
+	return nil
+}
+
+func receiveError(t *testing.T, errs chan error) error {
+	t.Helper()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "error wasn't received")
+	}
+
+	return nil
+}